@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync/atomic"
+)
+
+// Cache store the generate result of plans.
+// Each result is keyed by plan ID and the hash of envelope sent to rpc server,
+// so an outdated result will never be hit even if the invalidation is missed.
+type Cache interface {
+	Get(planID int64, hash string) (string, bool)
+	Set(planID int64, hash string, result string)
+
+	// Invalidate remove the cached results of the plans
+	Invalidate(planIDs ...int64)
+}
+
+// Stats is the counters of cache, exposed as metrics
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// Generate is the cache used for generate result, replace it to use other implementation.
+// Default to a cache storing nothing.
+var Generate Cache = nop{}
+
+var hits, misses uint64
+
+// Init create the in-memory cache holding no more than size plans' result,
+// size 0 disable the cache
+func Init(size int) {
+	if size > 0 {
+		Generate = NewMemory(size)
+	} else {
+		Generate = nop{}
+	}
+}
+
+// Hash return the hash of envelope used as part of key
+func Hash(envelope []byte) string {
+	sum := sha256.Sum256(envelope)
+	return hex.EncodeToString(sum[:])
+}
+
+// Get lookup the Generate cache and count the hit or miss
+func Get(planID int64, hash string) (string, bool) {
+	res, ok := Generate.Get(planID, hash)
+	if ok {
+		atomic.AddUint64(&hits, 1)
+	} else {
+		atomic.AddUint64(&misses, 1)
+	}
+	return res, ok
+}
+
+// Set store the result into Generate cache
+func Set(planID int64, hash string, result string) {
+	Generate.Set(planID, hash, result)
+}
+
+// Invalidate remove the results of plans from Generate cache
+func Invalidate(planIDs ...int64) {
+	Generate.Invalidate(planIDs...)
+}
+
+// GetStats return the hit and miss counters of Generate cache
+func GetStats() Stats {
+	return Stats{Hits: atomic.LoadUint64(&hits), Misses: atomic.LoadUint64(&misses)}
+}
+
+// nop is a cache storing nothing, used when cache is disabled
+type nop struct{}
+
+func (nop) Get(int64, string) (string, bool) { return "", false }
+func (nop) Set(int64, string, string)        {}
+func (nop) Invalidate(...int64)              {}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Memory is an in-process Cache, keep the latest result of each plan
+// and evict the least recently used plan when full.
+type Memory struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[int64]*list.Element
+}
+
+type memoryEntry struct {
+	planID int64
+	hash   string
+	result string
+}
+
+// NewMemory create a Memory cache holding no more than size plans' result
+func NewMemory(size int) *Memory {
+	return &Memory{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[int64]*list.Element),
+	}
+}
+
+// Get return the result if the cached one of plan has the same hash
+func (m *Memory) Get(planID int64, hash string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, exist := m.entries[planID]
+	if !exist {
+		return "", false
+	}
+	entry := elem.Value.(*memoryEntry)
+	if entry.hash != hash {
+		return "", false
+	}
+	m.lru.MoveToFront(elem)
+	return entry.result, true
+}
+
+// Set store the result of plan, replace the older one
+func (m *Memory) Set(planID int64, hash string, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, exist := m.entries[planID]; exist {
+		elem.Value = &memoryEntry{planID: planID, hash: hash, result: result}
+		m.lru.MoveToFront(elem)
+		return
+	}
+
+	m.entries[planID] = m.lru.PushFront(&memoryEntry{planID: planID, hash: hash, result: result})
+	for m.lru.Len() > m.size {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).planID)
+	}
+}
+
+// Invalidate remove the results of plans
+func (m *Memory) Invalidate(planIDs ...int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, planID := range planIDs {
+		if elem, exist := m.entries[planID]; exist {
+			m.lru.Remove(elem)
+			delete(m.entries, planID)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package cache
+
+import "testing"
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(2)
+
+	m.Set(1, "a", "plan 1")
+	if res, ok := m.Get(1, "a"); !ok || res != "plan 1" {
+		t.Errorf("Get(1, a) = %q, %v, want hit", res, ok)
+	}
+	// an outdated result is never hit
+	if _, ok := m.Get(1, "b"); ok {
+		t.Error("Get(1, b) hit the result of another envelope")
+	}
+
+	// the least recently used plan is evicted
+	m.Set(2, "a", "plan 2")
+	m.Get(1, "a")
+	m.Set(3, "a", "plan 3")
+	if _, ok := m.Get(2, "a"); ok {
+		t.Error("plan 2 is not evicted")
+	}
+	if _, ok := m.Get(1, "a"); !ok {
+		t.Error("plan 1 is evicted")
+	}
+
+	m.Invalidate(1, 4)
+	if _, ok := m.Get(1, "a"); ok {
+		t.Error("plan 1 is hit after invalidated")
+	}
+	if _, ok := m.Get(3, "a"); !ok {
+		t.Error("plan 3 is invalidated")
+	}
+}
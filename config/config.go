@@ -6,58 +6,285 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
 )
 
-// RPCTarget is the RPC server listen address and port, e: 127.0.0.1:8047
+// RPCTarget is the RPC server listen address and port, e: 127.0.0.1:8047.
+// Comma separated addresses or a DNS name resolving to many addresses, e: dns:///rpc.example.com:8047,
+// are balanced by round robin.
 var RPCTarget string
 
+// RPCHealthService is the service name checked by grpc health checking protocol, empty for the whole server
+var RPCHealthService string
+
+// TLS of the RPC channel, TLS is used if any of them is set, otherwise plaintext.
+// RPCTLSCA is the path of CA bundle to verify RPC server, system CAs are used if empty.
+// RPCTLSCert and RPCTLSKey are the path of client certificate and key for mutual TLS.
+// RPCTLSServerName is the name to verify RPC server's certificate, the host of RPCTarget if empty.
+var RPCTLSCA string
+var RPCTLSCert string
+var RPCTLSKey string
+var RPCTLSServerName string
+
 // RestEndpoint is the endpoint of rest server's IP address, e: 0.0.0.0
 var RestEndpoint string
 
-// Database connect info
+// RPCTimeout is the deadline in seconds of a generate call including retries, 0 to disable
+var RPCTimeout int
+
+// RPCMaxRetries is the max number of retries of a generate call failed by transient error
+var RPCMaxRetries int
+
+// RPCBreakerThreshold is the number of consecutive failed generate calls to open the circuit breaker,
+// 0 to disable the circuit breaker
+var RPCBreakerThreshold int
+
+// RPCBreakerCooldown is the seconds the circuit breaker keep open before calling rpc server again
+var RPCBreakerCooldown int
+
+// DatabaseDriver is the database backend, "mysql", "sqlite3" or "postgres"
+var DatabaseDriver string
+
+// Database connect info, with sqlite3 only DatabaseName is used as the path of database file
 var DatabaseUsername string
 var DatabasePassword string
 var DatabaseHost string
 var DatabaseName string
 
+// DatabaseSSLMode is the sslmode of postgres connection, "disable", "require", "verify-ca" or "verify-full"
+var DatabaseSSLMode string
+
 // HTTPBasepath is the base path while request this rest server, e: /api
 var HTTPBasepath string
 
+// HTTPSCert and HTTPSKey are the path of certificate and key to serve HTTPS, plain HTTP if empty.
+// They are reloaded on SIGHUP.
+var HTTPSCert string
+var HTTPSKey string
+
+// timeouts in seconds of reading request, writing response and keeping idle connection, 0 to disable
+var HTTPReadTimeout int
+var HTTPWriteTimeout int
+var HTTPIdleTimeout int
+
+// MetricsEndpoint is the address to serve metrics separately, e: 127.0.0.1:9100,
+// metrics are served by the rest server itself if empty
+var MetricsEndpoint string
+
+// MetricsPath is the path serving metrics in prometheus format, outside of HTTPBasepath
+var MetricsPath string
+
+// HealthPath and ReadyPath are the paths of liveness and readiness probes, outside of HTTPBasepath
+var HealthPath string
+var ReadyPath string
+
+// ShutdownTimeout is the seconds to wait the in-flight requests on SIGTERM or SIGINT
+var ShutdownTimeout int
+
+// TrustedProxies is the comma separated IPs or CIDRs of reverse proxies, e: 10.0.0.1,192.168.0.0/24.
+// Client IP is taken from X-Forwarded-For or X-Real-IP only if the request comes from them
+var TrustedProxies string
+
+// GenerateCacheSize is the max number of plans whose generate result is cached, 0 to disable
+var GenerateCacheSize int
+
+// ConfigSchema is the path of schema checking the options of config content on create and modify,
+// only the structure of content is checked if empty
+var ConfigSchema string
+
+// limits of requests per minute by route group, 0 to disable, and the requests allowed at once.
+// RateLimitAuth limit login and register by client IP,
+// RateLimitGenerate limit generate by plan token, or client IP with plan share or invalid token,
+// RateLimitAPI limit the others by user, or client IP for unauthorized visitors
+var RateLimitAuth int
+var RateLimitAuthBurst int
+var RateLimitGenerate int
+var RateLimitGenerateBurst int
+var RateLimitAPI int
+var RateLimitAPIBurst int
+
+// PasswordHasher is the algorithm to hash new password, "argon2id" or "bcrypt"
+var PasswordHasher string
+
+// cost parameters of password hasher
+var PasswordBcryptCost int
+var PasswordArgon2Memory int
+var PasswordArgon2Time int
+var PasswordArgon2Threads int
+
+// TokenSweepInterval is the interval in minutes to remove expired login token, 0 to disable
+var TokenSweepInterval int
+
+// TraceExporter is where the spans are exported, "none", "otlp" or "stdout"
+var TraceExporter string
+
+// TraceEndpoint is the address of OTLP collector, e: 127.0.0.1:4317,
+// or the path of file written by stdout exporter, standard output if empty
+var TraceEndpoint string
+
+// TraceSampleRate is the percentage of traces sampled, the sampling decision of upstream is respected
+var TraceSampleRate int
+
+// LogFormat is the format of log, "json" or "text"
+var LogFormat string
+
+// LogLevel is the minimum level of log, e: "debug", "info", "warn", "error"
+var LogLevel string
+
 // if InitDatabase is set, just init database but don't start server
 var InitDatabase bool
 
+// if Migrate is set, just migrate database schema to the latest version but don't start server
+var Migrate bool
+
+// if MigrateTo is not negative, just migrate database schema to this version but don't start server
+var MigrateTo int
+
+// if MigrateDryRun is set, print the pending SQL of migration instead of execute
+var MigrateDryRun bool
+
 // specifiy the path of config file
 var ConfigFile string
 
 // const name of each configuration
 type paramNames struct {
+	DatabaseDriver   string
 	DatabaseUsername string
 	DatabasePassword string
 	DatabaseHost     string
 	DatabaseName     string
+	DatabaseSSLMode  string
 
 	RPCTarget    string
 	RestEndpoint string
 	HTTPBasepath string
-	InitDatabase string
-	ConfigFile   string
+
+	HTTPSCert        string
+	HTTPSKey         string
+	HTTPReadTimeout  string
+	HTTPWriteTimeout string
+	HTTPIdleTimeout  string
+	ShutdownTimeout  string
+	TrustedProxies   string
+
+	MetricsEndpoint string
+	MetricsPath     string
+	HealthPath      string
+	ReadyPath       string
+
+	RPCHealthService    string
+	RPCTLSCA            string
+	RPCTLSCert          string
+	RPCTLSKey           string
+	RPCTLSServerName    string
+	RPCTimeout          string
+	RPCMaxRetries       string
+	RPCBreakerThreshold string
+	RPCBreakerCooldown  string
+
+	GenerateCacheSize string
+	ConfigSchema      string
+
+	RateLimitAuth          string
+	RateLimitAuthBurst     string
+	RateLimitGenerate      string
+	RateLimitGenerateBurst string
+	RateLimitAPI           string
+	RateLimitAPIBurst      string
+
+	TokenSweepInterval string
+
+	TraceExporter   string
+	TraceEndpoint   string
+	TraceSampleRate string
+
+	LogFormat string
+	LogLevel  string
+
+	PasswordHasher        string
+	PasswordBcryptCost    string
+	PasswordArgon2Memory  string
+	PasswordArgon2Time    string
+	PasswordArgon2Threads string
+
+	InitDatabase  string
+	Migrate       string
+	MigrateTo     string
+	MigrateDryRun string
+	ConfigFile    string
 }
 
 var pn paramNames = paramNames{
+	DatabaseDriver:   "database-driver",
 	DatabaseUsername: "database-username",
 	DatabasePassword: "database-password",
 	DatabaseHost:     "database-host",
 	DatabaseName:     "database-name",
+	DatabaseSSLMode:  "database-sslmode",
 
 	RPCTarget:    "rpc-target",
 	RestEndpoint: "rest-endpoint",
 	HTTPBasepath: "http-basepath",
-	InitDatabase: "init-database",
-	ConfigFile:   "config",
+
+	HTTPSCert:        "https-cert",
+	HTTPSKey:         "https-key",
+	HTTPReadTimeout:  "http-read-timeout",
+	HTTPWriteTimeout: "http-write-timeout",
+	HTTPIdleTimeout:  "http-idle-timeout",
+	ShutdownTimeout:  "shutdown-timeout",
+	TrustedProxies:   "trusted-proxies",
+
+	MetricsEndpoint: "metrics-endpoint",
+	MetricsPath:     "metrics-path",
+	HealthPath:      "health-path",
+	ReadyPath:       "ready-path",
+
+	RPCHealthService:    "rpc-health-service",
+	RPCTLSCA:            "rpc-tls-ca",
+	RPCTLSCert:          "rpc-tls-cert",
+	RPCTLSKey:           "rpc-tls-key",
+	RPCTLSServerName:    "rpc-tls-server-name",
+	RPCTimeout:          "rpc-timeout",
+	RPCMaxRetries:       "rpc-max-retries",
+	RPCBreakerThreshold: "rpc-breaker-threshold",
+	RPCBreakerCooldown:  "rpc-breaker-cooldown",
+
+	GenerateCacheSize: "generate-cache-size",
+	ConfigSchema:      "config-schema",
+
+	RateLimitAuth:          "rate-limit-auth",
+	RateLimitAuthBurst:     "rate-limit-auth-burst",
+	RateLimitGenerate:      "rate-limit-generate",
+	RateLimitGenerateBurst: "rate-limit-generate-burst",
+	RateLimitAPI:           "rate-limit-api",
+	RateLimitAPIBurst:      "rate-limit-api-burst",
+
+	TokenSweepInterval: "token-sweep-interval",
+
+	TraceExporter:   "trace-exporter",
+	TraceEndpoint:   "trace-endpoint",
+	TraceSampleRate: "trace-sample-rate",
+
+	LogFormat: "log-format",
+	LogLevel:  "log-level",
+
+	PasswordHasher:        "password-hasher",
+	PasswordBcryptCost:    "password-bcrypt-cost",
+	PasswordArgon2Memory:  "password-argon2-memory",
+	PasswordArgon2Time:    "password-argon2-time",
+	PasswordArgon2Threads: "password-argon2-threads",
+
+	InitDatabase:  "init-database",
+	Migrate:       "migrate",
+	MigrateTo:     "migrate-to",
+	MigrateDryRun: "migrate-dry-run",
+	ConfigFile:    "config",
 }
 
 // LoadConfig function load config from file whose path is confPath
@@ -98,19 +325,100 @@ func LoadConfigFromFile(confPath string) error {
 }
 
 func ParseParameter() {
+	flag.StringVar(&DatabaseDriver, pn.DatabaseDriver, "", "database backend, \"mysql\", \"sqlite3\" or \"postgres\"."+
+		" (default \"mysql\")")
 	flag.StringVar(&DatabaseUsername, pn.DatabaseUsername, "", "username used to login database service.")
 	flag.StringVar(&DatabasePassword, pn.DatabasePassword, "", "password used to login database service.")
-	flag.StringVar(&DatabaseHost, pn.DatabaseHost, "", "host of database service. e: 127.0.0.1:3306")
-	flag.StringVar(&DatabaseName, pn.DatabaseName, "", "name of database to use.")
+	flag.StringVar(&DatabaseHost, pn.DatabaseHost, "", "host of database service. e: 127.0.0.1:3306,"+
+		" or 127.0.0.1:5432 with postgres")
+	flag.StringVar(&DatabaseName, pn.DatabaseName, "", "name of database to use, or path of database file"+
+		" with sqlite3.")
+	flag.StringVar(&DatabaseSSLMode, pn.DatabaseSSLMode, "", "sslmode of postgres connection, \"disable\","+
+		" \"require\", \"verify-ca\" or \"verify-full\". (default \"require\")")
 
 	flag.StringVar(&RPCTarget, pn.RPCTarget, "", "RPCTarget is the RPC server listen address and port,"+
-		"e: 127.0.0.1:8047")
+		"e: 127.0.0.1:8047. comma separated addresses or dns:///<name>:<port> for many RPC servers")
+	flag.StringVar(&RPCHealthService, pn.RPCHealthService, "", "service name checked by grpc health checking"+
+		" protocol, empty for the whole server.")
 	flag.StringVar(&RestEndpoint, pn.RestEndpoint, "", "RestEndpoint is the endpoint of rest server's IP address,"+
 		"e: 0.0.0.0")
 	flag.StringVar(&HTTPBasepath, pn.HTTPBasepath, "", "HTTPBasepath is the base path while request this rest server,"+
 		"e: /api")
+	flag.StringVar(&RPCTLSCA, pn.RPCTLSCA, "", "path of CA bundle to verify RPC server, enable TLS of RPC channel."+
+		" system CAs are used if empty.")
+	flag.StringVar(&RPCTLSCert, pn.RPCTLSCert, "", "path of client certificate for mutual TLS of RPC channel.")
+	flag.StringVar(&RPCTLSKey, pn.RPCTLSKey, "", "path of client key for mutual TLS of RPC channel.")
+	flag.StringVar(&RPCTLSServerName, pn.RPCTLSServerName, "", "name to verify RPC server's certificate,"+
+		" enable TLS of RPC channel. the host of rpc-target if empty.")
+	flag.StringVar(&HTTPSCert, pn.HTTPSCert, "", "path of certificate to serve HTTPS, reloaded on SIGHUP.")
+	flag.StringVar(&HTTPSKey, pn.HTTPSKey, "", "path of key to serve HTTPS, reloaded on SIGHUP.")
+	flag.IntVar(&HTTPReadTimeout, pn.HTTPReadTimeout, -1, "timeout in seconds of reading request,"+
+		" 0 to disable. (default 30)")
+	flag.IntVar(&HTTPWriteTimeout, pn.HTTPWriteTimeout, -1, "timeout in seconds of writing response,"+
+		" 0 to disable. (default 60)")
+	flag.IntVar(&HTTPIdleTimeout, pn.HTTPIdleTimeout, -1, "timeout in seconds of keeping idle connection,"+
+		" 0 to disable. (default 120)")
+	flag.IntVar(&ShutdownTimeout, pn.ShutdownTimeout, -1, "seconds to wait the in-flight requests on"+
+		" SIGTERM or SIGINT. (default 30)")
+	flag.StringVar(&TrustedProxies, pn.TrustedProxies, "", "comma separated IPs or CIDRs of reverse proxies,"+
+		" whose X-Forwarded-For and X-Real-IP are trusted as client IP. none is trusted if empty.")
+	flag.StringVar(&MetricsEndpoint, pn.MetricsEndpoint, "", "address to serve metrics separately,"+
+		" e: 127.0.0.1:9100. served by the rest server itself if empty.")
+	flag.StringVar(&MetricsPath, pn.MetricsPath, "", "path serving metrics in prometheus format,"+
+		" outside of http-basepath. (default \"/metrics\")")
+	flag.StringVar(&HealthPath, pn.HealthPath, "", "path of liveness probe, outside of http-basepath."+
+		" (default \"/healthz\")")
+	flag.StringVar(&ReadyPath, pn.ReadyPath, "", "path of readiness probe checking database and RPC server,"+
+		" outside of http-basepath. (default \"/readyz\")")
+	flag.IntVar(&RPCTimeout, pn.RPCTimeout, -1, "deadline in seconds of a generate call including retries,"+
+		" 0 to disable. (default 10)")
+	flag.IntVar(&RPCMaxRetries, pn.RPCMaxRetries, -1, "max number of retries of a generate call failed by"+
+		" transient error. (default 2)")
+	flag.IntVar(&RPCBreakerThreshold, pn.RPCBreakerThreshold, -1, "number of consecutive failed generate calls"+
+		" to stop calling rpc server for a while, 0 to disable. (default 5)")
+	flag.IntVar(&RPCBreakerCooldown, pn.RPCBreakerCooldown, -1, "seconds to stop calling rpc server after"+
+		" too many failures. (default 30)")
+	flag.IntVar(&GenerateCacheSize, pn.GenerateCacheSize, -1, "max number of plans whose generate result is "+
+		"cached, 0 to disable cache. (default 1024)")
+	flag.StringVar(&ConfigSchema, pn.ConfigSchema, "", "path of schema checking the options of config content,"+
+		" only the structure is checked if empty.")
+	flag.IntVar(&RateLimitAuth, pn.RateLimitAuth, -1, "requests per minute of login and register by client IP,"+
+		" 0 to disable. (default 10)")
+	flag.IntVar(&RateLimitAuthBurst, pn.RateLimitAuthBurst, -1, "login and register requests allowed at once"+
+		" by client IP. (default 5)")
+	flag.IntVar(&RateLimitGenerate, pn.RateLimitGenerate, -1, "requests per minute of generate by plan token,"+
+		" or client IP with plan share or invalid token, 0 to disable. (default 30)")
+	flag.IntVar(&RateLimitGenerateBurst, pn.RateLimitGenerateBurst, -1, "generate requests allowed at once by"+
+		" plan token or client IP. (default 10)")
+	flag.IntVar(&RateLimitAPI, pn.RateLimitAPI, -1, "requests per minute of other apis by user, or client IP"+
+		" for unauthorized visitors, 0 to disable. (default 600)")
+	flag.IntVar(&RateLimitAPIBurst, pn.RateLimitAPIBurst, -1, "requests of other apis allowed at once by user"+
+		" or client IP. (default 100)")
+	flag.IntVar(&TokenSweepInterval, pn.TokenSweepInterval, -1, "interval in minutes to remove expired login "+
+		"token, 0 to disable. (default 240)")
+	flag.StringVar(&TraceExporter, pn.TraceExporter, "", "where the spans are exported, \"none\", \"otlp\""+
+		" or \"stdout\". (default \"none\")")
+	flag.StringVar(&TraceEndpoint, pn.TraceEndpoint, "", "address of OTLP collector, e: 127.0.0.1:4317, connected"+
+		" by TLS unless OTEL_EXPORTER_OTLP_INSECURE=true, or path of file written by stdout exporter.")
+	flag.IntVar(&TraceSampleRate, pn.TraceSampleRate, -1, "percentage of traces sampled, the decision of"+
+		" upstream is respected. (default 100)")
+	flag.StringVar(&LogFormat, pn.LogFormat, "", "format of log, \"json\" or \"text\". (default \"json\")")
+	flag.StringVar(&LogLevel, pn.LogLevel, "", "minimum level of log, e: \"debug\", \"info\", \"warn\"."+
+		" (default \"info\")")
+	flag.StringVar(&PasswordHasher, pn.PasswordHasher, "", "algorithm to hash new password, "+
+		"\"argon2id\" or \"bcrypt\". (default \"argon2id\")")
+	flag.IntVar(&PasswordBcryptCost, pn.PasswordBcryptCost, -1, "cost of bcrypt, in range [4, 31]. (default 10)")
+	flag.IntVar(&PasswordArgon2Memory, pn.PasswordArgon2Memory, -1, "memory used by argon2id in KiB. (default 65536)")
+	flag.IntVar(&PasswordArgon2Time, pn.PasswordArgon2Time, -1, "number of iterations of argon2id. (default 1)")
+	flag.IntVar(&PasswordArgon2Threads, pn.PasswordArgon2Threads, -1, "parallelism of argon2id. (default 4)")
 	flag.BoolVar(&InitDatabase, pn.InitDatabase, false, "add this parameter to init database and don't start server."+
 		" (this parameter can noly specified in command line)")
+	flag.BoolVar(&Migrate, pn.Migrate, false, "add this parameter to migrate database schema to the latest version"+
+		" and don't start server. (this parameter can noly specified in command line)")
+	flag.IntVar(&MigrateTo, pn.MigrateTo, -1, "migrate database schema to this version, lower version than current"+
+		" will revert the schema, and don't start server. (this parameter can noly specified in command line)")
+	flag.BoolVar(&MigrateDryRun, pn.MigrateDryRun, false, "print the pending SQL of migration instead of execute."+
+		" (this parameter can noly specified in command line)")
 	flag.StringVar(&ConfigFile, pn.ConfigFile, "", "specifiy the path of config file. "+
 		" (this parameter can noly specified in command line)")
 	flag.Parse()
@@ -130,7 +438,91 @@ func loadSingleConfig(key, value string) error {
 		if HTTPBasepath == "" {
 			HTTPBasepath = value
 		}
+	case pn.HTTPSCert:
+		if HTTPSCert == "" {
+			HTTPSCert = value
+		}
+	case pn.HTTPSKey:
+		if HTTPSKey == "" {
+			HTTPSKey = value
+		}
+	case pn.HTTPReadTimeout:
+		if HTTPReadTimeout < 0 {
+			return loadIntConfig(&HTTPReadTimeout, key, value)
+		}
+	case pn.HTTPWriteTimeout:
+		if HTTPWriteTimeout < 0 {
+			return loadIntConfig(&HTTPWriteTimeout, key, value)
+		}
+	case pn.HTTPIdleTimeout:
+		if HTTPIdleTimeout < 0 {
+			return loadIntConfig(&HTTPIdleTimeout, key, value)
+		}
+	case pn.ShutdownTimeout:
+		if ShutdownTimeout < 0 {
+			return loadIntConfig(&ShutdownTimeout, key, value)
+		}
+	case pn.TrustedProxies:
+		if TrustedProxies == "" {
+			TrustedProxies = value
+		}
+	case pn.MetricsEndpoint:
+		if MetricsEndpoint == "" {
+			MetricsEndpoint = value
+		}
+	case pn.MetricsPath:
+		if MetricsPath == "" {
+			MetricsPath = value
+		}
+	case pn.HealthPath:
+		if HealthPath == "" {
+			HealthPath = value
+		}
+	case pn.ReadyPath:
+		if ReadyPath == "" {
+			ReadyPath = value
+		}
+	case pn.RPCHealthService:
+		if RPCHealthService == "" {
+			RPCHealthService = value
+		}
+	case pn.RPCTLSCA:
+		if RPCTLSCA == "" {
+			RPCTLSCA = value
+		}
+	case pn.RPCTLSCert:
+		if RPCTLSCert == "" {
+			RPCTLSCert = value
+		}
+	case pn.RPCTLSKey:
+		if RPCTLSKey == "" {
+			RPCTLSKey = value
+		}
+	case pn.RPCTLSServerName:
+		if RPCTLSServerName == "" {
+			RPCTLSServerName = value
+		}
+	case pn.RPCTimeout:
+		if RPCTimeout < 0 {
+			return loadIntConfig(&RPCTimeout, key, value)
+		}
+	case pn.RPCMaxRetries:
+		if RPCMaxRetries < 0 {
+			return loadIntConfig(&RPCMaxRetries, key, value)
+		}
+	case pn.RPCBreakerThreshold:
+		if RPCBreakerThreshold < 0 {
+			return loadIntConfig(&RPCBreakerThreshold, key, value)
+		}
+	case pn.RPCBreakerCooldown:
+		if RPCBreakerCooldown < 0 {
+			return loadIntConfig(&RPCBreakerCooldown, key, value)
+		}
 
+	case pn.DatabaseDriver:
+		if DatabaseDriver == "" {
+			DatabaseDriver = value
+		}
 	case pn.DatabaseUsername:
 		if DatabaseUsername == "" {
 			DatabaseUsername = value
@@ -147,17 +539,220 @@ func loadSingleConfig(key, value string) error {
 		if DatabaseName == "" {
 			DatabaseName = value
 		}
+	case pn.DatabaseSSLMode:
+		if DatabaseSSLMode == "" {
+			DatabaseSSLMode = value
+		}
+
+	case pn.GenerateCacheSize:
+		if GenerateCacheSize < 0 {
+			return loadIntConfig(&GenerateCacheSize, key, value)
+		}
+	case pn.ConfigSchema:
+		if ConfigSchema == "" {
+			ConfigSchema = value
+		}
+	case pn.RateLimitAuth:
+		if RateLimitAuth < 0 {
+			return loadIntConfig(&RateLimitAuth, key, value)
+		}
+	case pn.RateLimitAuthBurst:
+		if RateLimitAuthBurst < 0 {
+			return loadIntConfig(&RateLimitAuthBurst, key, value)
+		}
+	case pn.RateLimitGenerate:
+		if RateLimitGenerate < 0 {
+			return loadIntConfig(&RateLimitGenerate, key, value)
+		}
+	case pn.RateLimitGenerateBurst:
+		if RateLimitGenerateBurst < 0 {
+			return loadIntConfig(&RateLimitGenerateBurst, key, value)
+		}
+	case pn.RateLimitAPI:
+		if RateLimitAPI < 0 {
+			return loadIntConfig(&RateLimitAPI, key, value)
+		}
+	case pn.RateLimitAPIBurst:
+		if RateLimitAPIBurst < 0 {
+			return loadIntConfig(&RateLimitAPIBurst, key, value)
+		}
+
+	case pn.TokenSweepInterval:
+		if TokenSweepInterval < 0 {
+			return loadIntConfig(&TokenSweepInterval, key, value)
+		}
+	case pn.TraceExporter:
+		if TraceExporter == "" {
+			TraceExporter = value
+		}
+	case pn.TraceEndpoint:
+		if TraceEndpoint == "" {
+			TraceEndpoint = value
+		}
+	case pn.TraceSampleRate:
+		if TraceSampleRate < 0 {
+			return loadIntConfig(&TraceSampleRate, key, value)
+		}
+	case pn.LogFormat:
+		if LogFormat == "" {
+			LogFormat = value
+		}
+	case pn.LogLevel:
+		if LogLevel == "" {
+			LogLevel = value
+		}
+	case pn.PasswordHasher:
+		if PasswordHasher == "" {
+			PasswordHasher = value
+		}
+	case pn.PasswordBcryptCost:
+		if PasswordBcryptCost < 0 {
+			return loadIntConfig(&PasswordBcryptCost, key, value)
+		}
+	case pn.PasswordArgon2Memory:
+		if PasswordArgon2Memory < 0 {
+			return loadIntConfig(&PasswordArgon2Memory, key, value)
+		}
+	case pn.PasswordArgon2Time:
+		if PasswordArgon2Time < 0 {
+			return loadIntConfig(&PasswordArgon2Time, key, value)
+		}
+	case pn.PasswordArgon2Threads:
+		if PasswordArgon2Threads < 0 {
+			return loadIntConfig(&PasswordArgon2Threads, key, value)
+		}
 	default:
 		return errors.New(fmt.Sprintf("unrecognized: %s = %s", key, value))
 	}
 	return nil
 }
 
+func loadIntConfig(target *int, key, value string) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid integer: %s = %s", key, value))
+	}
+	*target = v
+	return nil
+}
+
+// FillDefault set the default value of options which are not specified
+// in neither command line nor config file, call it after loading config file
+func FillDefault() {
+	if DatabaseDriver == "" {
+		DatabaseDriver = "mysql"
+	}
+	if DatabaseSSLMode == "" {
+		DatabaseSSLMode = "require"
+	}
+	if HTTPReadTimeout < 0 {
+		HTTPReadTimeout = 30
+	}
+	if HTTPWriteTimeout < 0 {
+		HTTPWriteTimeout = 60
+	}
+	if HTTPIdleTimeout < 0 {
+		HTTPIdleTimeout = 120
+	}
+	if ShutdownTimeout < 0 {
+		ShutdownTimeout = 30
+	}
+	if MetricsPath == "" {
+		MetricsPath = "/metrics"
+	}
+	if HealthPath == "" {
+		HealthPath = "/healthz"
+	}
+	if ReadyPath == "" {
+		ReadyPath = "/readyz"
+	}
+	if RPCTimeout < 0 {
+		RPCTimeout = 10
+	}
+	if RPCMaxRetries < 0 {
+		RPCMaxRetries = 2
+	}
+	if RPCBreakerThreshold < 0 {
+		RPCBreakerThreshold = 5
+	}
+	if RPCBreakerCooldown < 0 {
+		RPCBreakerCooldown = 30
+	}
+	if GenerateCacheSize < 0 {
+		GenerateCacheSize = 1024
+	}
+	if RateLimitAuth < 0 {
+		RateLimitAuth = 10
+	}
+	if RateLimitAuthBurst < 0 {
+		RateLimitAuthBurst = 5
+	}
+	if RateLimitGenerate < 0 {
+		RateLimitGenerate = 30
+	}
+	if RateLimitGenerateBurst < 0 {
+		RateLimitGenerateBurst = 10
+	}
+	if RateLimitAPI < 0 {
+		RateLimitAPI = 600
+	}
+	if RateLimitAPIBurst < 0 {
+		RateLimitAPIBurst = 100
+	}
+	if TokenSweepInterval < 0 {
+		TokenSweepInterval = 240
+	}
+	if TraceExporter == "" {
+		TraceExporter = "none"
+	}
+	if TraceSampleRate < 0 {
+		TraceSampleRate = 100
+	}
+	if LogFormat == "" {
+		LogFormat = "json"
+	}
+	if LogLevel == "" {
+		LogLevel = "info"
+	}
+	if PasswordHasher == "" {
+		PasswordHasher = "argon2id"
+	}
+	if PasswordBcryptCost < 0 {
+		PasswordBcryptCost = 10
+	}
+	if PasswordArgon2Memory < 0 {
+		PasswordArgon2Memory = 64 * 1024
+	}
+	if PasswordArgon2Time < 0 {
+		PasswordArgon2Time = 1
+	}
+	if PasswordArgon2Threads < 0 {
+		PasswordArgon2Threads = 4
+	}
+}
+
 func ValidParamCombination() error {
-	if InitDatabase {
+	if DatabaseDriver != "mysql" && DatabaseDriver != "sqlite3" && DatabaseDriver != "postgres" {
+		return errors.New(fmt.Sprintf("%s should be \"mysql\", \"sqlite3\" or \"postgres\"", pn.DatabaseDriver))
+	}
+	switch DatabaseSSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		return errors.New(fmt.Sprintf("%s should be \"disable\", \"require\", \"verify-ca\" or \"verify-full\"",
+			pn.DatabaseSSLMode))
+	}
+
+	if InitDatabase || IsMigrate() {
 		if !validDatabaseSource() {
-			return errors.New(fmt.Sprintf("when using %s, you must specify %s, %s, %s and %s",
-				pn.InitDatabase, pn.DatabaseUsername, pn.DatabasePassword, pn.DatabaseHost, pn.DatabaseName))
+			if DatabaseDriver == "sqlite3" {
+				return errors.New(fmt.Sprintf("when using %s or %s with sqlite3, you must specify %s",
+					pn.InitDatabase, pn.Migrate, pn.DatabaseName))
+			}
+			return errors.New(fmt.Sprintf("when using %s or %s, you must specify %s, %s, %s and %s",
+				pn.InitDatabase, pn.Migrate, pn.DatabaseUsername, pn.DatabasePassword, pn.DatabaseHost, pn.DatabaseName))
+		}
+		if InitDatabase && IsMigrate() {
+			return errors.New(fmt.Sprintf("%s and %s cannot be used together", pn.InitDatabase, pn.Migrate))
 		}
 	} else {
 		if !validDatabaseSource() ||
@@ -166,11 +761,70 @@ func ValidParamCombination() error {
 			RPCTarget == "" {
 			return errors.New("you haven't config all option")
 		}
+		if (HTTPSCert == "") != (HTTPSKey == "") {
+			return errors.New(fmt.Sprintf("%s and %s should be specified together", pn.HTTPSCert, pn.HTTPSKey))
+		}
+		if _, err := ParseTrustedProxies(); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(MetricsPath, "/") || !strings.HasPrefix(HealthPath, "/") ||
+			!strings.HasPrefix(ReadyPath, "/") {
+			return errors.New(fmt.Sprintf("%s, %s and %s should start with \"/\"",
+				pn.MetricsPath, pn.HealthPath, pn.ReadyPath))
+		}
+		if (RPCTLSCert == "") != (RPCTLSKey == "") {
+			return errors.New(fmt.Sprintf("%s and %s should be specified together", pn.RPCTLSCert, pn.RPCTLSKey))
+		}
+		if (RateLimitAuth > 0 && RateLimitAuthBurst < 1) || (RateLimitGenerate > 0 && RateLimitGenerateBurst < 1) ||
+			(RateLimitAPI > 0 && RateLimitAPIBurst < 1) {
+			return errors.New(fmt.Sprintf("%s, %s and %s should be positive if the limit is enabled",
+				pn.RateLimitAuthBurst, pn.RateLimitGenerateBurst, pn.RateLimitAPIBurst))
+		}
+		if TraceSampleRate > 100 {
+			return errors.New(fmt.Sprintf("%s should be in range [0, 100]", pn.TraceSampleRate))
+		}
+		if PasswordArgon2Time < 1 || PasswordArgon2Threads < 1 || PasswordArgon2Threads > 255 {
+			return errors.New(fmt.Sprintf("%s should be positive and %s should be in range [1, 255]",
+				pn.PasswordArgon2Time, pn.PasswordArgon2Threads))
+		}
+		if PasswordBcryptCost < bcrypt.MinCost || PasswordBcryptCost > bcrypt.MaxCost {
+			return errors.New(fmt.Sprintf("%s should be in range [%d, %d]",
+				pn.PasswordBcryptCost, bcrypt.MinCost, bcrypt.MaxCost))
+		}
 	}
 	return nil
 }
 
+// ParseTrustedProxies return the networks in TrustedProxies, a single IP is a network of itself
+func ParseTrustedProxies() ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, s := range strings.Split(TrustedProxies, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+			s += "/32"
+		} else if ip != nil {
+			s += "/128"
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %q is neither IP nor CIDR", pn.TrustedProxies, s))
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// IsMigrate return true if only migrate the database but don't start server
+func IsMigrate() bool {
+	return Migrate || MigrateTo >= 0
+}
+
 func validDatabaseSource() bool {
+	if DatabaseDriver == "sqlite3" {
+		return DatabaseName != ""
+	}
 	return DatabaseUsername != "" &&
 		DatabasePassword != "" &&
 		DatabaseHost != "" &&
@@ -181,14 +835,65 @@ func LogCurrentConfig() {
 	logrus.Info("========== current config ==========")
 	logrus.Infof("%20s = %s", pn.ConfigFile, ConfigFile)
 	logrus.Infof("%20s = %t", pn.InitDatabase, InitDatabase)
+	logrus.Infof("%20s = %t", pn.Migrate, Migrate)
+	logrus.Infof("%20s = %d", pn.MigrateTo, MigrateTo)
+	logrus.Infof("%20s = %t", pn.MigrateDryRun, MigrateDryRun)
 
+	logrus.Infof("%20s = %s", pn.DatabaseDriver, DatabaseDriver)
 	logrus.Infof("%20s = %s", pn.DatabaseUsername, DatabaseUsername)
 	logrus.Infof("%20s = %s", pn.DatabasePassword, strings.Repeat("*", len(DatabasePassword)))
 	logrus.Infof("%20s = %s", pn.DatabaseHost, DatabaseHost)
 	logrus.Infof("%20s = %s", pn.DatabaseName, DatabaseName)
+	logrus.Infof("%20s = %s", pn.DatabaseSSLMode, DatabaseSSLMode)
 
 	logrus.Infof("%20s = %s", pn.RPCTarget, RPCTarget)
 	logrus.Infof("%20s = %s", pn.RestEndpoint, RestEndpoint)
 	logrus.Infof("%20s = %s", pn.HTTPBasepath, HTTPBasepath)
+	logrus.Infof("%20s = %s", pn.HTTPSCert, HTTPSCert)
+	logrus.Infof("%20s = %s", pn.HTTPSKey, HTTPSKey)
+	logrus.Infof("%20s = %d", pn.HTTPReadTimeout, HTTPReadTimeout)
+	logrus.Infof("%20s = %d", pn.HTTPWriteTimeout, HTTPWriteTimeout)
+	logrus.Infof("%20s = %d", pn.HTTPIdleTimeout, HTTPIdleTimeout)
+	logrus.Infof("%20s = %d", pn.ShutdownTimeout, ShutdownTimeout)
+	logrus.Infof("%20s = %s", pn.TrustedProxies, TrustedProxies)
+	logrus.Infof("%20s = %s", pn.MetricsEndpoint, MetricsEndpoint)
+	logrus.Infof("%20s = %s", pn.MetricsPath, MetricsPath)
+	logrus.Infof("%20s = %s", pn.HealthPath, HealthPath)
+	logrus.Infof("%20s = %s", pn.ReadyPath, ReadyPath)
+
+	logrus.Infof("%20s = %s", pn.RPCHealthService, RPCHealthService)
+	logrus.Infof("%20s = %s", pn.RPCTLSCA, RPCTLSCA)
+	logrus.Infof("%20s = %s", pn.RPCTLSCert, RPCTLSCert)
+	logrus.Infof("%20s = %s", pn.RPCTLSKey, RPCTLSKey)
+	logrus.Infof("%20s = %s", pn.RPCTLSServerName, RPCTLSServerName)
+	logrus.Infof("%20s = %d", pn.RPCTimeout, RPCTimeout)
+	logrus.Infof("%20s = %d", pn.RPCMaxRetries, RPCMaxRetries)
+	logrus.Infof("%20s = %d", pn.RPCBreakerThreshold, RPCBreakerThreshold)
+	logrus.Infof("%20s = %d", pn.RPCBreakerCooldown, RPCBreakerCooldown)
+
+	logrus.Infof("%20s = %d", pn.GenerateCacheSize, GenerateCacheSize)
+	logrus.Infof("%20s = %s", pn.ConfigSchema, ConfigSchema)
+
+	logrus.Infof("%20s = %d", pn.RateLimitAuth, RateLimitAuth)
+	logrus.Infof("%20s = %d", pn.RateLimitAuthBurst, RateLimitAuthBurst)
+	logrus.Infof("%20s = %d", pn.RateLimitGenerate, RateLimitGenerate)
+	logrus.Infof("%20s = %d", pn.RateLimitGenerateBurst, RateLimitGenerateBurst)
+	logrus.Infof("%20s = %d", pn.RateLimitAPI, RateLimitAPI)
+	logrus.Infof("%20s = %d", pn.RateLimitAPIBurst, RateLimitAPIBurst)
+
+	logrus.Infof("%20s = %d", pn.TokenSweepInterval, TokenSweepInterval)
+
+	logrus.Infof("%20s = %s", pn.TraceExporter, TraceExporter)
+	logrus.Infof("%20s = %s", pn.TraceEndpoint, TraceEndpoint)
+	logrus.Infof("%20s = %d", pn.TraceSampleRate, TraceSampleRate)
+
+	logrus.Infof("%20s = %s", pn.LogFormat, LogFormat)
+	logrus.Infof("%20s = %s", pn.LogLevel, LogLevel)
+
+	logrus.Infof("%20s = %s", pn.PasswordHasher, PasswordHasher)
+	logrus.Infof("%20s = %d", pn.PasswordBcryptCost, PasswordBcryptCost)
+	logrus.Infof("%20s = %d", pn.PasswordArgon2Memory, PasswordArgon2Memory)
+	logrus.Infof("%20s = %d", pn.PasswordArgon2Time, PasswordArgon2Time)
+	logrus.Infof("%20s = %d", pn.PasswordArgon2Threads, PasswordArgon2Threads)
 	logrus.Info("======== current config end =========")
 }
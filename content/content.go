@@ -0,0 +1,79 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// value of t_config.c_format
+const (
+	FormatJSON int8 = 1
+	FormatTOML int8 = 2
+)
+
+// value of t_config.c_type
+const (
+	TypeGlobal int8 = 1
+	TypeLesson int8 = 2
+)
+
+// Parse decode the config content in its format to a generic json-like object,
+// the top level of content must be an object (or table in toml).
+func Parse(content string, format int8) (map[string]interface{}, error) {
+	var res map[string]interface{}
+	switch format {
+	case FormatJSON:
+		if err := json.Unmarshal([]byte(content), &res); err != nil {
+			return nil, fmt.Errorf("invalid json content: %s", err.Error())
+		}
+	case FormatTOML:
+		if _, err := toml.Decode(content, &res); err != nil {
+			return nil, fmt.Errorf("invalid toml content: %s", err.Error())
+		}
+		normalizeTimes(res)
+	default:
+		return nil, fmt.Errorf("unsupported config format: %d", format)
+	}
+
+	if res == nil {
+		return nil, errors.New("content must be an object")
+	}
+	return res, nil
+}
+
+// layouts of the toml date and time values without offset, told by the name of their location.
+// Written as these strings, they are the same as the config in json format.
+var localLayouts = map[string]string{
+	"date-local":     "2006-01-02",
+	"datetime-local": "2006-01-02T15:04:05.999999999",
+	"time-local":     "15:04:05.999999999",
+}
+
+// normalizeTimes replace the toml date and time values in v with the strings written in toml,
+// instead of the RFC 3339 form with offset marshaled from time.Time
+func normalizeTimes(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalizeTimes(e)
+		}
+	case []map[string]interface{}:
+		for _, e := range v {
+			normalizeTimes(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeTimes(e)
+		}
+	case time.Time:
+		if layout, local := localLayouts[v.Location().String()]; local {
+			return v.Format(layout)
+		}
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
@@ -0,0 +1,59 @@
+package content
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// the same config in toml and json is sent to rpc server as the same json
+func TestParseTOMLSameAsJSON(t *testing.T) {
+	tomlContent := `
+semesterStartDate = 2021-03-01
+publishedAt = 2021-02-20T08:30:00+08:00
+remindAt = 2021-03-01T07:50:00
+weeks = 18
+
+[[classTime]]
+start = 08:00:00
+end = 08:45:00
+
+[[classTime]]
+start = 08:55:00.5
+end = 09:40:00
+
+[holiday]
+dates = [2021-04-05, 2021-05-01]
+`
+	jsonContent := `{
+	"semesterStartDate": "2021-03-01",
+	"publishedAt": "2021-02-20T08:30:00+08:00",
+	"remindAt": "2021-03-01T07:50:00",
+	"weeks": 18,
+	"classTime": [
+		{"start": "08:00:00", "end": "08:45:00"},
+		{"start": "08:55:00.5", "end": "09:40:00"}
+	],
+	"holiday": {"dates": ["2021-04-05", "2021-05-01"]}
+}`
+
+	fromTOML, err := Parse(tomlContent, FormatTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := Parse(jsonContent, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tomlJSON, err := json.Marshal(fromTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonJSON, err := json.Marshal(fromJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tomlJSON) != string(jsonJSON) {
+		t.Errorf("toml is marshaled as\n%s\nwant\n%s", tomlJSON, jsonJSON)
+	}
+}
@@ -0,0 +1,91 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoGlobalConfig is returned when assembling an envelope without any global config
+var ErrNoGlobalConfig = errors.New("the plan has no global config")
+
+// Config is a config included in a plan, used to assemble the envelope sent to rpc server
+type Config struct {
+	ID      int64
+	Type    int8
+	Format  int8
+	Content string
+
+	// Shared is true if the config is added to the plan by config share
+	Shared bool
+
+	ModifyTime time.Time
+}
+
+// envelope is the json object received by rpc server
+type envelope struct {
+	Global  map[string]interface{}   `json:"global"`
+	Lessons []map[string]interface{} `json:"lessons"`
+}
+
+// BuildEnvelope assemble the configs of a plan to the json envelope required by rpc server.
+//
+// The rules are:
+//  1. there must be at least one global config, or ErrNoGlobalConfig is returned.
+//  2. a config added both directly and by its share is only used once.
+//  3. configs are applied in order: plan's own configs first and then shared configs,
+//     each ordered by config ID ascending.
+//  4. global configs are merged key by key in the order above,
+//     so the later one overrides the same top level option of the earlier.
+func BuildEnvelope(configs []Config) ([]byte, error) {
+	ordered := make([]Config, len(configs))
+	copy(ordered, configs)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Shared != ordered[j].Shared {
+			return !ordered[i].Shared
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+
+	var env envelope = envelope{Lessons: make([]map[string]interface{}, 0)}
+	var globalFrom = make(map[string]int64)
+	var used = make(map[int64]bool)
+	for _, conf := range ordered {
+		if used[conf.ID] {
+			continue
+		}
+		used[conf.ID] = true
+
+		parsed, err := Parse(conf.Content, conf.Format)
+		if err != nil {
+			return nil, fmt.Errorf("config %d: %s", conf.ID, err.Error())
+		}
+
+		switch conf.Type {
+		case TypeGlobal:
+			if env.Global == nil {
+				env.Global = make(map[string]interface{})
+			}
+			for k, v := range parsed {
+				if from, exist := globalFrom[k]; exist {
+					logrus.Warnf("global option %q of config %d is overridden by config %d", k, from, conf.ID)
+				}
+				env.Global[k] = v
+				globalFrom[k] = conf.ID
+			}
+		case TypeLesson:
+			env.Lessons = append(env.Lessons, parsed)
+		default:
+			return nil, fmt.Errorf("config %d: unsupported config type: %d", conf.ID, conf.Type)
+		}
+	}
+
+	if env.Global == nil {
+		return nil, ErrNoGlobalConfig
+	}
+	return json.Marshal(env)
+}
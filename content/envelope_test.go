@@ -0,0 +1,145 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestBuildEnvelope(t *testing.T) {
+	global := func(id int64, content string, shared bool) Config {
+		return Config{ID: id, Type: TypeGlobal, Format: FormatJSON, Content: content, Shared: shared}
+	}
+	lesson := func(id int64, name string, shared bool) Config {
+		return Config{ID: id, Type: TypeLesson, Format: FormatJSON, Content: `{"name": "` + name + `"}`, Shared: shared}
+	}
+
+	cases := []struct {
+		name    string
+		configs []Config
+		want    string
+		err     error
+		// warnings are the substrings of the warnings logged, sorted
+		warnings []string
+	}{
+		{
+			name:    "no config",
+			configs: nil,
+			err:     ErrNoGlobalConfig,
+		},
+		{
+			name:    "no global config",
+			configs: []Config{lesson(1, "math", false), lesson(2, "physics", true)},
+			err:     ErrNoGlobalConfig,
+		},
+		{
+			name:    "global only",
+			configs: []Config{global(1, `{"timezone": "UTC"}`, false)},
+			want:    `{"global": {"timezone": "UTC"}, "lessons": []}`,
+		},
+		{
+			name: "own configs before shared ones, each by ID",
+			configs: []Config{
+				lesson(5, "shared-5", true), lesson(9, "own-9", false), lesson(2, "shared-2", true),
+				global(7, `{}`, false), lesson(3, "own-3", false),
+			},
+			want: `{"global": {}, "lessons": [{"name": "own-3"}, {"name": "own-9"}, {"name": "shared-2"}, {"name": "shared-5"}]}`,
+		},
+		{
+			name: "config added both directly and by share is used once",
+			configs: []Config{
+				global(1, `{"timezone": "UTC"}`, false), lesson(4, "math", true), lesson(4, "math", false),
+				global(1, `{"timezone": "UTC"}`, true),
+			},
+			want: `{"global": {"timezone": "UTC"}, "lessons": [{"name": "math"}]}`,
+		},
+		{
+			name: "later global overrides the same option with a warning",
+			configs: []Config{
+				global(8, `{"timezone": "Asia/Shanghai", "weeks": 16}`, true),
+				global(3, `{"timezone": "UTC", "start": "2021-03-01"}`, false),
+				global(6, `{"weeks": 18}`, false),
+			},
+			want: `{"global": {"timezone": "Asia/Shanghai", "start": "2021-03-01", "weeks": 16}, "lessons": []}`,
+			warnings: []string{
+				`"timezone" of config 3 is overridden by config 8`,
+				`"weeks" of config 6 is overridden by config 8`,
+			},
+		},
+		{
+			name: "toml and json configs",
+			configs: []Config{
+				{ID: 1, Type: TypeGlobal, Format: FormatTOML, Content: "start = 2021-03-01\n"},
+				{ID: 2, Type: TypeLesson, Format: FormatTOML, Content: "name = \"math\"\n"},
+			},
+			want: `{"global": {"start": "2021-03-01"}, "lessons": [{"name": "math"}]}`,
+		},
+		{
+			name:    "broken content",
+			configs: []Config{global(1, `{}`, false), {ID: 2, Type: TypeLesson, Format: FormatJSON, Content: `[`}},
+			err:     errors.New("config 2: invalid json content"),
+		},
+		{
+			name:    "unknown type",
+			configs: []Config{global(1, `{}`, false), {ID: 2, Type: 3, Format: FormatJSON, Content: `{}`}},
+			err:     errors.New("config 2: unsupported config type: 3"),
+		},
+	}
+
+	hook := logtest.NewGlobal()
+	for _, c := range cases {
+		hook.Reset()
+		got, err := BuildEnvelope(c.configs)
+
+		switch {
+		case c.err == ErrNoGlobalConfig:
+			if err != ErrNoGlobalConfig {
+				t.Errorf("%s: error %v, want %v", c.name, err, c.err)
+			}
+			continue
+		case c.err != nil:
+			if err == nil || !strings.HasPrefix(err.Error(), c.err.Error()) {
+				t.Errorf("%s: error %v, want %v", c.name, err, c.err)
+			}
+			continue
+		case err != nil:
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+
+		var gotValue, wantValue interface{}
+		if err = json.Unmarshal(got, &gotValue); err != nil {
+			t.Fatalf("%s: invalid json %s", c.name, got)
+		}
+		if err = json.Unmarshal([]byte(c.want), &wantValue); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			t.Errorf("%s: envelope %s, want %s", c.name, got, c.want)
+		}
+
+		var warnings []string
+		for _, entry := range hook.AllEntries() {
+			if entry.Level == logrus.WarnLevel {
+				warnings = append(warnings, entry.Message)
+			}
+		}
+		// the options of a config are applied in the random order of map
+		sort.Strings(warnings)
+		if len(warnings) != len(c.warnings) {
+			t.Errorf("%s: warnings %q, want %q", c.name, warnings, c.warnings)
+			continue
+		}
+		for i := range warnings {
+			if !strings.Contains(warnings[i], c.warnings[i]) {
+				t.Errorf("%s: warning %q, want %q", c.name, warnings[i], c.warnings[i])
+			}
+		}
+	}
+}
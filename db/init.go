@@ -2,11 +2,15 @@ package db
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/jmoiron/sqlx"
 
 	// for the side effect of driver register
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
 )
 
@@ -16,13 +20,48 @@ var DB *sqlx.DB
 // Init initialize the db, make it usable
 func Init() error {
 	var err error
-
-	// auto parse database type datetime as []uint8 in go to time.Time
-	DB, err = sqlx.Connect("mysql", dsn(config.DatabaseUsername, config.DatabasePassword,
-		config.DatabaseHost, config.DatabaseName))
+	DB, err = connect(config.DatabaseName)
 	return err
 }
 
+// connect to the database of configured driver, name is the database to use
+func connect(name string) (*sqlx.DB, error) {
+	switch config.DatabaseDriver {
+	case "sqlite3":
+		return sqlx.Connect("sqlite3", sqliteDSN(name))
+	case "postgres":
+		return sqlx.Connect("postgres", postgresDSN(config.DatabaseUsername, config.DatabasePassword,
+			config.DatabaseHost, name, config.DatabaseSSLMode))
+	default:
+		// auto parse database type datetime as []uint8 in go to time.Time
+		return sqlx.Connect("mysql", dsn(config.DatabaseUsername, config.DatabasePassword,
+			config.DatabaseHost, name))
+	}
+}
+
 func dsn(username, password, host, name string) string {
 	return fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", username, password, host, name)
 }
+
+// foreign key is disabled by default in sqlite.
+// begin transaction immediately, so the check-then-insert in transaction won't fail
+// when upgrading the read lock to write lock.
+func sqliteDSN(path string) string {
+	return fmt.Sprintf("file:%s?_foreign_keys=1&_busy_timeout=5000&_txlock=immediate&_journal_mode=WAL", path)
+}
+
+// time is stored without time zone, so the session time zone is fixed to UTC
+// to make now() consistent between connections.
+// The CA to verify server is read from PGSSLROOTCERT environment, system CAs if empty,
+// and the client certificate from PGSSLCERT and PGSSLKEY, or ~/.postgresql.
+func postgresDSN(username, password, host, name, sslmode string) string {
+	query := url.Values{"sslmode": {sslmode}, "timezone": {"UTC"}}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(username, password),
+		Host:     host,
+		Path:     "/" + name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
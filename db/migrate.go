@@ -0,0 +1,196 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+// %s is the type of c_apply_time, postgresql has no datetime
+const sqlCreateSchemaVersion = `
+create table if not exists t_schema_version (
+	c_version integer primary key,
+	c_name varchar(64),
+	c_apply_time %s not null default current_timestamp
+);`
+
+func createSchemaVersion(conn *sqlx.DB) error {
+	timeType := "datetime"
+	if conn.DriverName() == "postgres" {
+		timeType = "timestamp"
+	}
+	_, err := conn.Exec(fmt.Sprintf(sqlCreateSchemaVersion, timeType))
+	return err
+}
+
+// LatestVersion return the version of the last migration of database driver
+func LatestVersion(driver string) int {
+	migrations := migrationsOf(driver)
+	return migrations[len(migrations)-1].Version
+}
+
+// Migrate apply the up or down steps to make the schema of conn in target version,
+// target -1 means the latest version.
+// With dryRun, the SQL of pending steps is written to out and nothing is executed.
+func Migrate(conn *sqlx.DB, target int, dryRun bool, out io.Writer) error {
+	migrations := migrationsOf(conn.DriverName())
+	latest := LatestVersion(conn.DriverName())
+	if target < 0 {
+		target = latest
+	}
+	if target > latest {
+		return fmt.Errorf("target version %d is newer than the latest version %d", target, latest)
+	}
+
+	current, err := currentVersion(conn, migrations, dryRun)
+	if err != nil {
+		return err
+	}
+	if current > latest {
+		// e: the binary is rolled back after migrating by a newer one
+		return fmt.Errorf("database schema version %d is newer than supported %d", current, latest)
+	}
+	logrus.Infof("schema version: current %d, target %d", current, target)
+
+	for current < target {
+		m := migrations[current]
+		if err = applyStep(conn, m.Version, m.Name, m.Up, true, dryRun, out); err != nil {
+			return err
+		}
+		current++
+	}
+	for current > target {
+		m := migrations[current-1]
+		if err = applyStep(conn, m.Version, m.Name, m.Down, false, dryRun, out); err != nil {
+			return err
+		}
+		current--
+	}
+	return nil
+}
+
+// currentVersion return the version recorded in t_schema_version, 0 for empty database.
+// database initialized before migration is introduced is treated as version 1.
+func currentVersion(conn *sqlx.DB, migrations []Migration, dryRun bool) (int, error) {
+	versionTableExist, err := tableExist(conn, "t_schema_version")
+	if err != nil {
+		return 0, err
+	}
+
+	if !versionTableExist {
+		userTableExist, err := tableExist(conn, "t_user")
+		if err != nil {
+			return 0, err
+		}
+		if !userTableExist {
+			if !dryRun {
+				err = createSchemaVersion(conn)
+			}
+			return 0, err
+		}
+
+		logrus.Warn("database without schema version found, treat it as version 1")
+		if !dryRun {
+			if err = createSchemaVersion(conn); err != nil {
+				return 0, err
+			}
+			_, err = conn.Exec(conn.Rebind("insert into t_schema_version (c_version, c_name) values (?, ?)"),
+				migrations[0].Version, migrations[0].Name)
+		}
+		return 1, err
+	}
+
+	var version int
+	err = conn.Get(&version, "select coalesce(max(c_version), 0) from t_schema_version")
+	return version, err
+}
+
+func tableExist(conn *sqlx.DB, name string) (bool, error) {
+	var cnt int
+	var err error
+	switch conn.DriverName() {
+	case "sqlite3":
+		err = conn.Get(&cnt, "select count(*) from sqlite_master where type = 'table' and name = ?", name)
+	case "postgres":
+		err = conn.Get(&cnt, "select count(*) from information_schema.tables"+
+			" where table_schema = current_schema() and table_name = $1", name)
+	default:
+		err = conn.Get(&cnt, "select count(*) from information_schema.tables"+
+			" where table_schema = database() and table_name = ?", name)
+	}
+	return cnt > 0, err
+}
+
+// execer is the database or transaction running the statements of a step
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Rebind(query string) string
+}
+
+// execute the statements of one step and record the version, in a transaction with sqlite3 and postgres.
+// DDL of mysql is committed implicitly, so a failed step of mysql may leave the statements
+// before the failed one applied while the version is not recorded.
+func applyStep(conn *sqlx.DB, version int, name string, commands string, up bool, dryRun bool, out io.Writer) error {
+	direction := directionOf(up)
+
+	if dryRun {
+		fmt.Fprintf(out, "-- version %d %s: %s\n%s\n", version, direction, name, strings.TrimSpace(commands))
+		return nil
+	}
+
+	logrus.Infof("migrating version %d %s: %s", version, direction, name)
+	if conn.DriverName() == "mysql" {
+		return runStep(conn, version, name, commands, up)
+	}
+
+	tx, err := conn.Beginx()
+	if err != nil {
+		return err
+	}
+	if err = runStep(tx, version, name, commands, up); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logrus.Error(rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func runStep(conn execer, version int, name string, commands string, up bool) error {
+	for _, command := range splitStatements(commands) {
+		if _, err := conn.Exec(command); err != nil {
+			logrus.Info(command)
+			return fmt.Errorf("version %d %s: %s", version, directionOf(up), err.Error())
+		}
+	}
+
+	var err error
+	if up {
+		_, err = conn.Exec(conn.Rebind("insert into t_schema_version (c_version, c_name) values (?, ?)"), version, name)
+	} else {
+		_, err = conn.Exec(conn.Rebind("delete from t_schema_version where c_version = ?"), version)
+	}
+	return err
+}
+
+func directionOf(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
+
+func splitStatements(commands string) []string {
+	var res []string
+	for _, command := range strings.Split(commands, ";") {
+		if len(strings.Trim(command, " \t\n;")) == 0 {
+			continue
+		}
+		res = append(res, command)
+	}
+	return res
+}
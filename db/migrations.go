@@ -0,0 +1,358 @@
+package db
+
+// Migration is a numbered step of schema change.
+// Up upgrade the schema from Version-1 to Version, and Down revert it.
+// Statements are separated by semicolon.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migrationsOf return the migrations of database driver,
+// every driver has its own history of schema.
+func migrationsOf(driver string) []Migration {
+	switch driver {
+	case "sqlite3":
+		return sqliteMigrations
+	case "postgres":
+		return postgresMigrations
+	default:
+		return mysqlMigrations
+	}
+}
+
+// mysqlMigrations must be ordered by Version, and Version must start from 1 and be continuous.
+// Never modify an applied migration, append a new one instead.
+var mysqlMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Up: `
+create table t_user (
+	c_id integer primary key AUTO_INCREMENT,
+	c_email varchar(64),
+	c_nickname varchar(32),
+	c_username varchar(32),
+	c_password binary(32),
+	c_bio varchar(300) default '',
+	c_join_time datetime not null default now()
+);
+
+create table t_config (
+	c_id integer primary key AUTO_INCREMENT,
+	c_type tinyint,                 # 1-global, 2-lesson
+	c_name varchar(64),
+	c_content varchar(1024),
+	c_format tinyint,				# 1-json, 2-toml
+	c_owner_id integer,
+	c_remark varchar(300),
+	c_create_time datetime not null default now(), # default create time is now()
+	c_modify_time datetime not null default now(), # default modify time is now()
+	c_deleted bool default false,
+	
+	constraint foreign key (c_owner_id) references t_user (c_id)
+);
+
+create table t_config_share (
+	c_id integer primary key AUTO_INCREMENT,
+	# c_access_uid varchar(64) unique,
+	c_config_id integer,
+	c_create_time datetime not null default now(),
+	c_remark varchar(300),
+	c_deleted bool default false,
+	
+	constraint foreign key (c_config_id) references t_config (c_id)
+);
+
+create table t_user_favourite_config (
+	c_id integer primary key AUTO_INCREMENT,
+	c_user_id integer,
+	c_config_share_id integer,
+	c_create_time datetime not null default now(), # default create time is now()
+	
+	constraint foreign key (c_user_id) references t_user (c_id),
+	constraint foreign key (c_config_share_id) references t_config_share (c_id)
+);
+
+create table t_plan (
+	c_id integer primary key AUTO_INCREMENT,
+	c_name varchar(64),
+	c_owner_id integer,
+	c_remark varchar(300),
+	c_create_time datetime not null default now(), # default create time is now()
+	c_modify_time datetime not null default now(), # default modify time is now()
+	c_deleted bool default false,
+	
+	constraint foreign key (c_owner_id) references t_user (c_id)
+);
+
+create table t_plan_config_relation (
+	c_id integer primary key AUTO_INCREMENT,
+	c_plan_id integer,
+	c_config_id integer,
+	
+	constraint foreign key (c_plan_id) references t_plan (c_id),
+	constraint foreign key (c_config_id) references t_config (c_id)
+);
+
+create table t_plan_config_share_relation (
+	c_id integer primary key AUTO_INCREMENT,
+	c_plan_id integer,
+	c_config_share_id integer,
+	
+	constraint foreign key (c_plan_id) references t_plan (c_id),
+	constraint foreign key (c_config_share_id) references t_config_share(c_id)
+);
+
+create table t_plan_share (
+	c_id integer primary key AUTO_INCREMENT,
+	# c_access_uid varchar(64) unique, # length of UUID
+	c_plan_id integer,
+	c_create_time datetime not null default now(),
+	c_remark varchar(300),
+	c_deleted bool default false,
+	
+	constraint foreign key (c_plan_id) references t_plan (c_id)
+);
+
+create table t_user_favourite_plan (
+	c_id integer primary key AUTO_INCREMENT,
+	c_user_id integer,
+	c_plan_share_id integer,
+	c_create_time datetime not null default now(), # default create time is now()
+	
+	constraint foreign key (c_user_id) references t_user (c_id),
+	constraint foreign key (c_plan_share_id) references t_plan_share (c_id)
+);
+
+create table t_login_token (
+	c_id integer primary key AUTO_INCREMENT,
+	c_user_id integer,
+	c_token varchar(32), # token will be uuid string removed dashes
+	c_expire_time datetime not null default date_add(now(), interval 3 day),
+	
+	constraint foreign key (c_user_id) references t_user (c_id)
+);
+
+create table t_plan_token (
+	c_id integer primary key AUTO_INCREMENT,
+	c_token varchar(32) unique, # token will be uuid string removed dashes
+	c_plan_id integer,
+	c_create_time datetime not null default now(),
+	
+	constraint foreign key (c_plan_id) references t_plan (c_id)
+);
+
+
+# auto delete expired token
+create event auto_remove_expired_token 
+	on schedule every 4 hour
+	comment 'auto delete expired token'
+	do
+		delete from t_login_token where c_expire_time > now();
+
+# update the c_modify_time to now() on table t_config
+create trigger t_config_update_modify_time
+	before update on t_config
+	for each row
+	set new.c_modify_time = now();
+
+# update the c_modify_time to now() on table t_plan
+create trigger t_plan_update_modify_time
+	before update on t_plan
+	for each row
+	set new.c_modify_time = now();
+
+# update the c_modify_time to now() on table t_plan when
+# 1. add config to plan
+# 2. remove config from plan
+# 3. add config shared to plan
+# 4. remove config shared from plan
+create trigger t_plan_update_modify_time_relationship_insert
+	after insert on t_plan_config_relation 
+	for each row
+	update t_plan set c_modify_time = now() where c_id = new.c_plan_id;
+create trigger t_plan_update_modify_time_relationship_delete
+	after delete on t_plan_config_relation 
+	for each row
+	update t_plan set c_modify_time = now() where c_id = old.c_plan_id;
+create trigger t_plan_update_modify_time_share_relationship_insert
+	after insert on t_plan_config_share_relation
+	for each row
+	update t_plan set c_modify_time = now() where c_id = new.c_plan_id;
+create trigger t_plan_update_modify_time_share_relationship_delete
+	after delete on t_plan_config_share_relation 
+	for each row
+	update t_plan set c_modify_time = now() where c_id = old.c_plan_id;
+`,
+		Down: `
+drop event if exists auto_remove_expired_token;
+drop table if exists t_plan_token;
+drop table if exists t_login_token;
+drop table if exists t_user_favourite_plan;
+drop table if exists t_plan_share;
+drop table if exists t_plan_config_share_relation;
+drop table if exists t_plan_config_relation;
+drop table if exists t_plan;
+drop table if exists t_user_favourite_config;
+drop table if exists t_config_share;
+drop table if exists t_config;
+drop table if exists t_user;
+`,
+	},
+	{
+		Version: 2,
+		Name:    "widen password column for prefixed hash",
+		Up: `
+alter table t_user modify c_password varbinary(128);
+`,
+		// hashes other than legacy sha256 will be truncated
+		Down: `
+alter table t_user modify c_password binary(32);
+`,
+	},
+	{
+		Version: 3,
+		Name:    "login session detail",
+		Up: `
+alter table t_login_token
+	add column c_user_agent varchar(256) default '',
+	add column c_ip varchar(64) default '',
+	add column c_create_time datetime not null default now(),
+	add column c_last_used_time datetime not null default now();
+`,
+		Down: `
+alter table t_login_token
+	drop column c_user_agent,
+	drop column c_ip,
+	drop column c_create_time,
+	drop column c_last_used_time;
+`,
+	},
+	{
+		Version: 4,
+		Name:    "login token duration and fix expired token event",
+		Up: `
+alter table t_login_token add column c_duration integer not null default 3; # in days, used to renew the token
+
+drop event if exists auto_remove_expired_token;
+create event auto_remove_expired_token
+	on schedule every 4 hour
+	comment 'auto delete expired token'
+	do
+		delete from t_login_token where c_expire_time < now();
+`,
+		// keep the fixed event, the original one removes valid tokens
+		Down: `
+alter table t_login_token drop column c_duration;
+`,
+	},
+	{
+		Version: 5,
+		Name:    "api key",
+		Up: `
+create table t_api_key (
+	c_id integer primary key AUTO_INCREMENT,
+	c_user_id integer,
+	c_name varchar(64),
+	c_key_hash binary(32) unique,   # sha256 of key, the key itself is not stored
+	c_prefix varchar(16),           # the beginning of key, help user recognize the key
+	c_scopes varchar(64),           # comma separated, e: read,configs
+	c_create_time datetime not null default now(),
+	c_last_used_time datetime,
+	c_expire_time datetime,         # null means never expire
+
+	constraint foreign key (c_user_id) references t_user (c_id)
+);
+`,
+		Down: `
+drop table if exists t_api_key;
+`,
+	},
+	{
+		Version: 6,
+		Name:    "unique relations and favourites",
+		Up: `
+# remove the duplicated rows created by concurrent requests, keep the earliest one
+delete r1 from t_plan_config_relation as r1 join t_plan_config_relation as r2
+	on r1.c_plan_id = r2.c_plan_id and r1.c_config_id = r2.c_config_id and r1.c_id > r2.c_id;
+delete r1 from t_plan_config_share_relation as r1 join t_plan_config_share_relation as r2
+	on r1.c_plan_id = r2.c_plan_id and r1.c_config_share_id = r2.c_config_share_id and r1.c_id > r2.c_id;
+delete f1 from t_user_favourite_config as f1 join t_user_favourite_config as f2
+	on f1.c_user_id = f2.c_user_id and f1.c_config_share_id = f2.c_config_share_id and f1.c_id > f2.c_id;
+delete f1 from t_user_favourite_plan as f1 join t_user_favourite_plan as f2
+	on f1.c_user_id = f2.c_user_id and f1.c_plan_share_id = f2.c_plan_share_id and f1.c_id > f2.c_id;
+
+alter table t_plan_config_relation add unique key uk_plan_config (c_plan_id, c_config_id);
+alter table t_plan_config_share_relation add unique key uk_plan_config_share (c_plan_id, c_config_share_id);
+alter table t_user_favourite_config add unique key uk_user_config_share (c_user_id, c_config_share_id);
+alter table t_user_favourite_plan add unique key uk_user_plan_share (c_user_id, c_plan_share_id);
+`,
+		// the foreign key on c_plan_id or c_user_id needs an index, so add it before dropping unique key
+		Down: `
+alter table t_plan_config_relation add key (c_plan_id), drop key uk_plan_config;
+alter table t_plan_config_share_relation add key (c_plan_id), drop key uk_plan_config_share;
+alter table t_user_favourite_config add key (c_user_id), drop key uk_user_config_share;
+alter table t_user_favourite_plan add key (c_user_id), drop key uk_user_plan_share;
+`,
+	},
+	{
+		Version: 7,
+		Name:    "move triggers and event into application",
+		// modify time and expired token are maintained by rest server,
+		// so every database backend behaves the same
+		Up: `
+drop event if exists auto_remove_expired_token;
+drop trigger if exists t_config_update_modify_time;
+drop trigger if exists t_plan_update_modify_time;
+drop trigger if exists t_plan_update_modify_time_relationship_insert;
+drop trigger if exists t_plan_update_modify_time_relationship_delete;
+drop trigger if exists t_plan_update_modify_time_share_relationship_insert;
+drop trigger if exists t_plan_update_modify_time_share_relationship_delete;
+`,
+		Down: `
+create event auto_remove_expired_token
+	on schedule every 4 hour
+	comment 'auto delete expired token'
+	do
+		delete from t_login_token where c_expire_time < now();
+
+create trigger t_config_update_modify_time
+	before update on t_config
+	for each row
+	set new.c_modify_time = now();
+create trigger t_plan_update_modify_time
+	before update on t_plan
+	for each row
+	set new.c_modify_time = now();
+create trigger t_plan_update_modify_time_relationship_insert
+	after insert on t_plan_config_relation
+	for each row
+	update t_plan set c_modify_time = now() where c_id = new.c_plan_id;
+create trigger t_plan_update_modify_time_relationship_delete
+	after delete on t_plan_config_relation
+	for each row
+	update t_plan set c_modify_time = now() where c_id = old.c_plan_id;
+create trigger t_plan_update_modify_time_share_relationship_insert
+	after insert on t_plan_config_share_relation
+	for each row
+	update t_plan set c_modify_time = now() where c_id = new.c_plan_id;
+create trigger t_plan_update_modify_time_share_relationship_delete
+	after delete on t_plan_config_share_relation
+	for each row
+	update t_plan set c_modify_time = now() where c_id = old.c_plan_id;
+`,
+	},
+	{
+		Version: 8,
+		Name:    "count generations of plan",
+		Up: `
+alter table t_plan add column c_generate_count integer not null default 0;
+`,
+		Down: `
+alter table t_plan drop column c_generate_count;
+`,
+	},
+}
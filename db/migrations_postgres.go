@@ -0,0 +1,157 @@
+package db
+
+// postgresMigrations is the schema history of postgresql, which starts from the schema
+// equal to version 7 of mysql. The same rules of mysqlMigrations apply.
+//
+// All timestamp are stored without time zone in UTC, the session time zone is set to UTC on connect.
+var postgresMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Up: `
+create table t_user (
+	c_id serial primary key,
+	c_email varchar(64),
+	c_nickname varchar(32),
+	c_username varchar(32),
+	c_password bytea,
+	c_bio varchar(300) default '',
+	c_join_time timestamp not null default current_timestamp
+);
+
+create table t_config (
+	c_id serial primary key,
+	c_type smallint,                -- 1-global, 2-lesson
+	c_name varchar(64),
+	c_content varchar(1024),
+	c_format smallint,              -- 1-json, 2-toml
+	c_owner_id integer references t_user (c_id),
+	c_remark varchar(300),
+	c_create_time timestamp not null default current_timestamp,
+	c_modify_time timestamp not null default current_timestamp,
+	c_deleted boolean default false
+);
+create index idx_config_owner on t_config (c_owner_id);
+
+create table t_config_share (
+	c_id serial primary key,
+	c_config_id integer references t_config (c_id),
+	c_create_time timestamp not null default current_timestamp,
+	c_remark varchar(300),
+	c_deleted boolean default false
+);
+create index idx_config_share_config on t_config_share (c_config_id);
+
+create table t_user_favourite_config (
+	c_id serial primary key,
+	c_user_id integer references t_user (c_id),
+	c_config_share_id integer references t_config_share (c_id),
+	c_create_time timestamp not null default current_timestamp,
+
+	constraint uk_user_config_share unique (c_user_id, c_config_share_id)
+);
+
+create table t_plan (
+	c_id serial primary key,
+	c_name varchar(64),
+	c_owner_id integer references t_user (c_id),
+	c_remark varchar(300),
+	c_create_time timestamp not null default current_timestamp,
+	c_modify_time timestamp not null default current_timestamp,
+	c_deleted boolean default false
+);
+create index idx_plan_owner on t_plan (c_owner_id);
+
+create table t_plan_config_relation (
+	c_id serial primary key,
+	c_plan_id integer references t_plan (c_id),
+	c_config_id integer references t_config (c_id),
+
+	constraint uk_plan_config unique (c_plan_id, c_config_id)
+);
+
+create table t_plan_config_share_relation (
+	c_id serial primary key,
+	c_plan_id integer references t_plan (c_id),
+	c_config_share_id integer references t_config_share (c_id),
+
+	constraint uk_plan_config_share unique (c_plan_id, c_config_share_id)
+);
+
+create table t_plan_share (
+	c_id serial primary key,
+	c_plan_id integer references t_plan (c_id),
+	c_create_time timestamp not null default current_timestamp,
+	c_remark varchar(300),
+	c_deleted boolean default false
+);
+create index idx_plan_share_plan on t_plan_share (c_plan_id);
+
+create table t_user_favourite_plan (
+	c_id serial primary key,
+	c_user_id integer references t_user (c_id),
+	c_plan_share_id integer references t_plan_share (c_id),
+	c_create_time timestamp not null default current_timestamp,
+
+	constraint uk_user_plan_share unique (c_user_id, c_plan_share_id)
+);
+
+create table t_login_token (
+	c_id serial primary key,
+	c_user_id integer references t_user (c_id),
+	c_token varchar(32),            -- token will be uuid string removed dashes
+	c_expire_time timestamp not null default (current_timestamp + interval '3 days'),
+	c_duration integer not null default 3, -- in days, used to renew the token
+	c_user_agent varchar(256) default '',
+	c_ip varchar(64) default '',
+	c_create_time timestamp not null default current_timestamp,
+	c_last_used_time timestamp not null default current_timestamp
+);
+create index idx_login_token_token on t_login_token (c_token);
+create index idx_login_token_user on t_login_token (c_user_id);
+
+create table t_plan_token (
+	c_id serial primary key,
+	c_token varchar(32) unique,     -- token will be uuid string removed dashes
+	c_plan_id integer references t_plan (c_id),
+	c_create_time timestamp not null default current_timestamp
+);
+
+create table t_api_key (
+	c_id serial primary key,
+	c_user_id integer references t_user (c_id),
+	c_name varchar(64),
+	c_key_hash bytea unique,        -- sha256 of key, the key itself is not stored
+	c_prefix varchar(16),           -- the beginning of key, help user recognize the key
+	c_scopes varchar(64),           -- comma separated, e: read,configs
+	c_create_time timestamp not null default current_timestamp,
+	c_last_used_time timestamp,
+	c_expire_time timestamp         -- null means never expire
+);
+`,
+		Down: `
+drop table if exists t_api_key;
+drop table if exists t_plan_token;
+drop table if exists t_login_token;
+drop table if exists t_user_favourite_plan;
+drop table if exists t_plan_share;
+drop table if exists t_plan_config_share_relation;
+drop table if exists t_plan_config_relation;
+drop table if exists t_plan;
+drop table if exists t_user_favourite_config;
+drop table if exists t_config_share;
+drop table if exists t_config;
+drop table if exists t_user;
+`,
+	},
+	{
+		Version: 2,
+		Name:    "count generations of plan",
+		Up: `
+alter table t_plan add column c_generate_count integer not null default 0;
+`,
+		Down: `
+alter table t_plan drop column c_generate_count;
+`,
+	},
+}
@@ -127,3 +127,16 @@ type ConfigShareGetListReq struct {
 type ConfigShareGetListRes struct {
 	Shares []ConfigShareDetail `json:"shares" binding:"required"`
 }
+
+// ConfigFieldError describe a problem found in config's content,
+// Field is "type", "content", or the path of the problem option, e: schedule[0].week
+type ConfigFieldError struct {
+	Field   string `json:"field" binding:"required"`
+	Message string `json:"message" binding:"required"`
+}
+
+// ConfigInvalidRes is used as response data when config's content failed to pass validation
+type ConfigInvalidRes struct {
+	Message string             `json:"message" binding:"required"`
+	Errors  []ConfigFieldError `json:"errors" binding:"required"`
+}
@@ -11,3 +11,26 @@ type GenerateByPlanShareReq struct {
 type GenerateRes struct {
 	Content string `json:"content" binding:"required"`
 }
+
+// PlanGeneratePreviewReq is used to preview the generate result of a plan.
+// Configs is optional, used to replace the content of configs in plan (matched by ID)
+// or add new configs (without ID) without saving them.
+type PlanGeneratePreviewReq struct {
+	ID      int64               `json:"id" binding:"required"`
+	Configs []PlanPreviewConfig `json:"configs"`
+}
+
+// PlanPreviewConfig is the unsaved config used in PlanGeneratePreviewReq.
+// Type is only required when adding a new config
+type PlanPreviewConfig struct {
+	ID      int64  `json:"id"`
+	Type    int8   `json:"type"`
+	Format  int8   `json:"format"`
+	Content string `json:"content"`
+}
+
+// PlanGeneratePreviewRes contains the generate result and the envelope sent to rpc server
+type PlanGeneratePreviewRes struct {
+	Content  string `json:"content" binding:"required"`
+	Envelope string `json:"envelope" binding:"required"`
+}
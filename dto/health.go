@@ -0,0 +1,9 @@
+package dto
+
+// DependencyStatus is the result of checking a dependency by health probes
+type DependencyStatus struct {
+	// Status is "ok" or "bad"
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latencyMs"`
+}
@@ -62,6 +62,9 @@ type PlanGetRes struct {
 	ModifyTime time.Time      `json:"modifyTime" binding:"required"`
 	Configs    []ConfigDetail `json:"configs" binding:"required"`
 
+	// the number of times the plan generated by token or share
+	GenerateCount int64 `json:"generateCount" binding:"required"`
+
 	// the share's detail is the same as configs, but its ID is shareID, not configID
 	Shares []ConfigDetail `json:"shares" binding:"required"`
 }
@@ -49,3 +49,26 @@ type PlanShareDetail struct {
 	Remark     string    `db:"c_remark" json:"remark" binding:"required"`
 	CreateTime time.Time `db:"c_create_time" json:"createTime" binding:"required"`
 }
+
+type SessionDetail struct {
+	ID           int64     `db:"c_id" json:"id" binding:"required"`
+	UserAgent    string    `db:"c_user_agent" json:"userAgent" binding:"required"`
+	IP           string    `db:"c_ip" json:"ip" binding:"required"`
+	CreateTime   time.Time `db:"c_create_time" json:"createTime" binding:"required"`
+	LastUsedTime time.Time `db:"c_last_used_time" json:"lastUsedTime" binding:"required"`
+	ExpireTime   time.Time `db:"c_expire_time" json:"expireTime" binding:"required"`
+
+	// Current is true if the session is the one sending this request
+	Current bool `json:"current" binding:"required"`
+}
+
+type APIKeyDetail struct {
+	ID           int64      `db:"c_id" json:"id" binding:"required"`
+	Name         string     `db:"c_name" json:"name" binding:"required"`
+	Prefix       string     `db:"c_prefix" json:"prefix" binding:"required"`
+	Scopes       string     `db:"c_scopes" json:"-"`
+	ScopeList    []string   `json:"scopes" binding:"required"`
+	CreateTime   time.Time  `db:"c_create_time" json:"createTime" binding:"required"`
+	LastUsedTime *time.Time `db:"c_last_used_time" json:"lastUsedTime"`
+	ExpireTime   *time.Time `db:"c_expire_time" json:"expireTime"`
+}
@@ -1,7 +1,5 @@
 package dto
 
-import "crypto/sha256"
-
 // Full Database Properties
 // ID       int64     `db:"c_id" json:"id" binding:"required"`
 // Email    string    `db:"c_email" json:"email" binding:"required"`
@@ -41,22 +39,42 @@ type UserLoginRes struct {
 	ID int64 `db:"c_id" json:"id" binding:"required"`
 }
 
-func (u *UserRegisterReq) fillPasswordHash() {
-	if u.Password != nil {
-		return
-	}
-	var hash [32]byte = sha256.Sum256([]byte(u.PasswordPlain))
-	var hashSlice []byte = make([]byte, 0, 32)
-	copy(hashSlice, hash[:])
-	u.Password = hashSlice
-}
-
-func (u *UserLoginReq) fillPasswordHash() {
-	if u.Password != nil {
-		return
-	}
-	var hash [32]byte = sha256.Sum256([]byte(u.PasswordPlain))
-	var hashSlice []byte = make([]byte, 0, 32)
-	copy(hashSlice, hash[:])
-	u.Password = hashSlice
+// SessionListRes is the response of session list request, list all login sessions of user
+type SessionListRes struct {
+	Sessions []SessionDetail `json:"sessions" binding:"required"`
+}
+
+// SessionRevokeReq is used to revoke a login session of user
+type SessionRevokeReq struct {
+	ID int64 `json:"id" binding:"required"`
+}
+
+type SessionRevokeRes string
+
+type LogoutAllRes string
+
+// APIKeyCreateReq is used to create an api key for scripts.
+// available scopes: "read", "configs", "plans", "admin"
+type APIKeyCreateReq struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+
+	// valid period in days, 0 or omitted means never expire
+	ExpireDays int `json:"expireDays"`
+}
+
+// APIKeyCreateRes contains the created key, the key could not be got again
+type APIKeyCreateRes struct {
+	ID  int64  `json:"id" binding:"required"`
+	Key string `json:"key" binding:"required"`
+}
+
+type APIKeyRevokeReq struct {
+	ID int64 `json:"id" binding:"required"`
+}
+
+type APIKeyRevokeRes string
+
+type APIKeyListRes struct {
+	Keys []APIKeyDetail `json:"keys" binding:"required"`
 }
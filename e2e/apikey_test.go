@@ -0,0 +1,99 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keyPost send req as json to path authorized by the api key, and return the status code
+func keyPost(t *testing.T, key string, path string, req interface{}) int {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+key)
+	res, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+// every api key could read, but need the scope to edit, and admin to manage sessions and api keys
+func TestAPIKeyScopes(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "apikey-owner")
+	keys := make(map[string]string)
+	for _, scope := range []string{"read", "configs", "plans", "admin"} {
+		var res struct {
+			Key string `json:"key"`
+		}
+		owner.mustPost("/api-key-create", gin.H{"name": scope, "scopes": []string{scope}}, &res)
+		keys[scope] = res.Key
+	}
+
+	configCreate := gin.H{"name": "semester", "type": 1, "format": 1, "content": globalContent, "remark": "key"}
+	planCreate := gin.H{"name": "plan", "remark": "key"}
+	list := gin.H{"sortBy": "id", "count": 10}
+	var plan idRes
+	owner.mustPost("/plan-create", planCreate, &plan)
+	tokenList := gin.H{"id": plan.ID}
+	cases := []struct {
+		scope string
+		path  string
+		req   gin.H
+		want  int
+	}{
+		{"read", "/config-get-list", list, http.StatusOK},
+		{"read", "/plan-get-list", list, http.StatusOK},
+		{"read", "/config-create", configCreate, http.StatusForbidden},
+		{"read", "/plan-create", planCreate, http.StatusForbidden},
+		{"configs", "/config-create", configCreate, http.StatusOK},
+		{"configs", "/plan-create", planCreate, http.StatusForbidden},
+		{"plans", "/plan-create", planCreate, http.StatusOK},
+		// plan token is secret as api key
+		{"plans", "/plan-get-token-list", tokenList, http.StatusOK},
+		{"read", "/plan-get-token-list", tokenList, http.StatusForbidden},
+		{"configs", "/plan-get-token-list", tokenList, http.StatusForbidden},
+
+		// admin routes reject the api keys without admin scope
+		{"read", "/session-list", gin.H{}, http.StatusForbidden},
+		{"configs", "/api-key-list", gin.H{}, http.StatusForbidden},
+		{"plans", "/api-key-create", gin.H{"name": "escalate", "scopes": []string{"admin"}}, http.StatusForbidden},
+		{"plans", "/session-revoke", gin.H{"id": 1}, http.StatusForbidden},
+		{"plans", "/logout-all", gin.H{}, http.StatusForbidden},
+		{"admin", "/session-list", gin.H{}, http.StatusOK},
+		{"admin", "/api-key-list", gin.H{}, http.StatusOK},
+		{"admin", "/config-create", configCreate, http.StatusOK},
+	}
+	for _, c := range cases {
+		if code := keyPost(t, keys[c.scope], c.path, c.req); code != c.want {
+			t.Errorf("%s by %s key: status %d, want %d", c.path, c.scope, code, c.want)
+		}
+	}
+
+	// the rejected requests take no effect
+	var keyList struct {
+		Keys []struct {
+			Name string `json:"name"`
+		} `json:"keys"`
+	}
+	owner.mustPost("/api-key-list", gin.H{}, &keyList)
+	for _, k := range keyList.Keys {
+		if k.Name == "escalate" {
+			t.Error("api-key-create: api key created by a key without admin scope")
+		}
+	}
+	owner.mustPost("/session-list", gin.H{}, nil)
+}
@@ -0,0 +1,128 @@
+package e2e
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/cache"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/ratelimit"
+)
+
+// recordCache record the plans invalidated from the wrapped cache
+type recordCache struct {
+	cache.Cache
+	mu          sync.Mutex
+	invalidated map[int64]bool
+}
+
+func (r *recordCache) Invalidate(planIDs ...int64) {
+	r.mu.Lock()
+	for _, id := range planIDs {
+		r.invalidated[id] = true
+	}
+	r.mu.Unlock()
+	r.Cache.Invalidate(planIDs...)
+}
+
+// take return whether the plan is invalidated since last take
+func (r *recordCache) take(planID int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invalidated := r.invalidated[planID]
+	r.invalidated = make(map[int64]bool)
+	return invalidated
+}
+
+// noLimit is a rate limit store never limiting, so a test could generate as many times as it needs
+type noLimit struct{}
+
+func (noLimit) Take(string, ratelimit.Limit) (bool, time.Duration, error) { return true, 0, nil }
+
+// the generate result is served from cache until the plan, its configs or config shares are edited
+func TestGenerateCache(t *testing.T) {
+	useBuckets(t, noLimit{})
+	record := &recordCache{Cache: cache.NewMemory(16), invalidated: make(map[int64]bool)}
+	old := cache.Generate
+	cache.Generate = record
+	t.Cleanup(func() { cache.Generate = old })
+
+	sharer := newClient(t)
+	registerAndLogin(t, sharer, "cache-sharer")
+	var shared, share idRes
+	sharer.mustPost("/config-create", gin.H{
+		"name": "physics", "type": 2, "format": 1, "content": lessonContent, "remark": "shared",
+	}, &shared)
+	sharer.mustPost("/config-share-create", gin.H{"id": shared.ID, "remark": "share"}, &share)
+
+	owner := newClient(t)
+	registerAndLogin(t, owner, "cache-owner")
+	var global, lesson, plan idRes
+	owner.mustPost("/config-create", gin.H{
+		"name": "semester", "type": 1, "format": 1, "content": globalContent, "remark": "global",
+	}, &global)
+	owner.mustPost("/config-create", gin.H{
+		"name": "algebra", "type": 2, "format": 1, "content": lessonContent, "remark": "lesson",
+	}, &lesson)
+	owner.mustPost("/plan-create", gin.H{"name": "cached", "remark": "cached"}, &plan)
+	owner.mustPost("/plan-add-config", gin.H{"planId": plan.ID, "configId": global.ID}, nil)
+	owner.mustPost("/plan-add-config", gin.H{"planId": plan.ID, "configId": lesson.ID}, nil)
+	owner.mustPost("/plan-add-share", gin.H{"planId": plan.ID, "configShareId": share.ID}, nil)
+	var token struct {
+		Token string `json:"token"`
+	}
+	owner.mustPost("/plan-create-token", gin.H{"id": plan.ID}, &token)
+
+	// generate the plan and check if the rpc server is called
+	generate := func(step string, wantCall bool) {
+		t.Helper()
+		calls, hits := generator.calls(), cache.GetStats().Hits
+		res := owner.get("/generate-by-plan-token?token="+url.QueryEscape(token.Token), nil)
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK || string(body) != fakeCalendar {
+			t.Fatalf("%s: generate status %d, body %q", step, res.StatusCode, body)
+		}
+		if called := generator.calls() != calls; called != wantCall {
+			t.Errorf("%s: rpc server called %v, want %v", step, called, wantCall)
+		}
+		if hit := cache.GetStats().Hits != hits; hit == wantCall {
+			t.Errorf("%s: cache hit %v, want %v", step, hit, !wantCall)
+		}
+	}
+
+	generate("first generate", true)
+	generate("generate again", false)
+
+	edits := []struct {
+		name string
+		c    *client
+		path string
+		req  gin.H
+	}{
+		{"plan-modify", owner, "/plan-modify", gin.H{"id": plan.ID, "name": "renamed", "remark": "cached"}},
+		{"config-modify", owner, "/config-modify", gin.H{
+			"id": lesson.ID, "name": "algebra II", "format": 1, "content": lessonContent, "remark": "lesson"}},
+		{"config-share-modify", sharer, "/config-share-modify", gin.H{"id": share.ID, "remark": "edited"}},
+		{"config-share-revoke", sharer, "/config-share-revoke", gin.H{"id": share.ID}},
+		{"config-remove", owner, "/config-remove", gin.H{"id": lesson.ID}},
+	}
+	for _, edit := range edits {
+		record.take(plan.ID)
+		edit.c.mustPost(edit.path, edit.req, nil)
+		if !record.take(plan.ID) {
+			t.Errorf("%s: plan not invalidated", edit.name)
+		}
+		generate(edit.name, true)
+		generate(edit.name+" then generate again", false)
+	}
+
+	owner.mustPost("/plan-remove", gin.H{"id": plan.ID}, nil)
+	if !record.take(plan.ID) {
+		t.Error("plan-remove: plan not invalidated")
+	}
+}
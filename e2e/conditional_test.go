@@ -0,0 +1,70 @@
+package e2e
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// removing a config or revoking a config share used by the plan changes the calendar,
+// so the copy validated by Last-Modified before is no longer fresh
+func TestRevalidateAfterConfigRemoved(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "revalidate-owner")
+	sharer := newClient(t)
+	registerAndLogin(t, sharer, "revalidate-sharer")
+
+	var global, lesson, shared, share, plan idRes
+	owner.mustPost("/config-create", gin.H{
+		"name": "semester", "type": 1, "format": 1, "content": globalContent, "remark": "global",
+	}, &global)
+	owner.mustPost("/config-create", gin.H{
+		"name": "algebra", "type": 2, "format": 1, "content": lessonContent, "remark": "lesson",
+	}, &lesson)
+	sharer.mustPost("/config-create", gin.H{
+		"name": "shared", "type": 2, "format": 1, "content": lessonContent, "remark": "shared",
+	}, &shared)
+	sharer.mustPost("/config-share-create", gin.H{"id": shared.ID, "remark": "share"}, &share)
+
+	owner.mustPost("/plan-create", gin.H{"name": "spring", "remark": "spring semester"}, &plan)
+	owner.mustPost("/plan-add-config", gin.H{"planId": plan.ID, "configId": global.ID}, nil)
+	owner.mustPost("/plan-add-config", gin.H{"planId": plan.ID, "configId": lesson.ID}, nil)
+	owner.mustPost("/plan-add-share", gin.H{"planId": plan.ID, "configShareId": share.ID}, nil)
+	var token struct {
+		Token string `json:"token"`
+	}
+	owner.mustPost("/plan-create-token", gin.H{"id": plan.ID}, &token)
+	path := "/generate-by-plan-token?token=" + url.QueryEscape(token.Token)
+
+	res := owner.get(path, nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("generate: status %d", res.StatusCode)
+	}
+	lastModified := res.Header.Get("Last-Modified")
+
+	for _, change := range []struct {
+		name  string
+		apply func()
+	}{
+		{"config removed", func() { owner.mustPost("/config-remove", gin.H{"id": lesson.ID}, nil) }},
+		{"config share revoked", func() { sharer.mustPost("/config-share-revoke", gin.H{"id": share.ID}, nil) }},
+	} {
+		// the precision of Last-Modified is second
+		time.Sleep(1100 * time.Millisecond)
+		change.apply()
+
+		res = owner.get(path, http.Header{"If-Modified-Since": {lastModified}})
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("revalidate after %s: status %d, want %d", change.name, res.StatusCode, http.StatusOK)
+		}
+		if res.Header.Get("Last-Modified") == lastModified {
+			t.Errorf("revalidate after %s: Last-Modified is not updated", change.name)
+		}
+		lastModified = res.Header.Get("Last-Modified")
+	}
+}
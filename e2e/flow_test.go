@@ -0,0 +1,244 @@
+package e2e
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const globalContent = `{"semesterStartDate": "2021-03-01", "timezone": "Asia/Shanghai",
+	"classTime": [{"start": "08:00", "end": "08:45"}, {"start": "08:55", "end": "09:40"}]}`
+
+const lessonContent = `{"name": "Linear Algebra", "teacher": "Alice", "location": "Room 101",
+	"schedule": [{"week": "1-16", "day": 1, "time": "1-2"}]}`
+
+type idRes struct {
+	ID int64 `json:"id"`
+}
+
+// register, login, create configs, build a plan, create its token and generate by the token
+func TestGenerateByPlanToken(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "generate-owner")
+
+	var global, lesson, plan idRes
+	owner.mustPost("/config-create", gin.H{
+		"name": "semester", "type": 1, "format": 1, "content": globalContent, "remark": "global",
+	}, &global)
+	owner.mustPost("/config-create", gin.H{
+		"name": "algebra", "type": 2, "format": 1, "content": lessonContent, "remark": "lesson",
+	}, &lesson)
+	owner.mustPost("/plan-create", gin.H{"name": "spring", "remark": "spring semester"}, &plan)
+	owner.mustPost("/plan-add-config", gin.H{"planId": plan.ID, "configId": global.ID}, nil)
+	owner.mustPost("/plan-add-config", gin.H{"planId": plan.ID, "configId": lesson.ID}, nil)
+
+	var token struct {
+		Token string `json:"token"`
+	}
+	owner.mustPost("/plan-create-token", gin.H{"id": plan.ID}, &token)
+	if token.Token == "" {
+		t.Fatal("empty plan token")
+	}
+
+	// the token is the only credential of calendar subscription
+	subscriber := newClient(t)
+	calls := generator.calls()
+	res := subscriber.get("/generate-by-plan-token?token="+url.QueryEscape(token.Token), nil)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("generate: status %d, body %s", res.StatusCode, body)
+	}
+	if string(body) != fakeCalendar {
+		t.Errorf("generate: body %q, want %q", body, fakeCalendar)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("generate: content type %q", ct)
+	}
+	if generator.calls() != calls+1 {
+		t.Fatalf("generate: rpc called %d times, want 1", generator.calls()-calls)
+	}
+	envelope := generator.lastEnvelope()
+	if !strings.Contains(envelope, "2021-03-01") || !strings.Contains(envelope, "Linear Algebra") {
+		t.Errorf("generate: envelope %s miss the configs of plan", envelope)
+	}
+
+	// unchanged plan is not generated again
+	etag := res.Header.Get("ETag")
+	res = subscriber.get("/generate-by-plan-token?token="+url.QueryEscape(token.Token),
+		http.Header{"If-None-Match": {etag}})
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("conditional generate: status %d, want %d", res.StatusCode, http.StatusNotModified)
+	}
+	if generator.calls() != calls+1 {
+		t.Errorf("conditional generate: rpc called again")
+	}
+
+	// revoked token no longer works
+	owner.mustPost("/plan-revoke-token", gin.H{"token": token.Token}, nil)
+	res = subscriber.get("/generate-by-plan-token?token="+url.QueryEscape(token.Token), nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("generate by revoked token: status %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestRegisterDuplicated(t *testing.T) {
+	c := newClient(t)
+	registerAndLogin(t, c, "duplicated")
+
+	code := c.post("/register", gin.H{
+		"username": "duplicated", "password": "another", "nickname": "another", "email": "another@example.com",
+	}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("register duplicated username: status %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	c := newClient(t)
+	registerAndLogin(t, newClient(t), "wrong-password")
+
+	code := c.post("/login", gin.H{"username": "wrong-password", "password": "guess", "tokenDuration": 1}, nil)
+	if code != http.StatusBadRequest {
+		t.Errorf("login: status %d, want %d", code, http.StatusBadRequest)
+	}
+	if code = c.post("/config-get-list", gin.H{"sortBy": "id", "count": 10}, nil); code != http.StatusForbidden {
+		t.Errorf("config list without login: status %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+// only the owner could add its configs to the plan
+func TestPlanAddOthersConfig(t *testing.T) {
+	alice, bob := newClient(t), newClient(t)
+	registerAndLogin(t, alice, "plan-alice")
+	registerAndLogin(t, bob, "plan-bob")
+
+	var config, plan idRes
+	alice.mustPost("/config-create", gin.H{
+		"name": "semester", "type": 1, "format": 1, "content": globalContent, "remark": "global",
+	}, &config)
+	bob.mustPost("/plan-create", gin.H{"name": "stolen", "remark": "stolen"}, &plan)
+
+	code := bob.post("/plan-add-config", gin.H{"planId": plan.ID, "configId": config.ID}, nil)
+	if code == http.StatusOK {
+		t.Error("plan-add-config: others' config is added")
+	}
+}
+
+// the request ID is echoed in response and sent to rpc server as metadata
+func TestRequestID(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "request-id")
+	token := createPlanToken(t, owner)
+
+	res := owner.get("/generate-by-plan-token?token="+url.QueryEscape(token),
+		http.Header{"X-Request-Id": {"e2e-request-1"}})
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("generate: status %d", res.StatusCode)
+	}
+	if id := res.Header.Get("X-Request-ID"); id != "e2e-request-1" {
+		t.Errorf("generate: response request ID %q, want %q", id, "e2e-request-1")
+	}
+	if id := generator.lastRequestID(); id != "e2e-request-1" {
+		t.Errorf("generate: rpc metadata request ID %q, want %q", id, "e2e-request-1")
+	}
+
+	// assigned if not provided
+	res = owner.get("/generate-by-plan-share?shareId=0", nil)
+	res.Body.Close()
+	if res.Header.Get("X-Request-ID") == "" {
+		t.Error("generate-by-plan-share: no request ID assigned")
+	}
+}
+
+// adding a config, config share or favor twice is a conflict, and the first one is kept
+func TestDuplicateRelations(t *testing.T) {
+	sharer := newClient(t)
+	registerAndLogin(t, sharer, "duplicate-sharer")
+	var shared, configShare, sharedPlan, planShare idRes
+	sharer.mustPost("/config-create", gin.H{
+		"name": "physics", "type": 2, "format": 1, "content": lessonContent, "remark": "shared",
+	}, &shared)
+	sharer.mustPost("/config-share-create", gin.H{"id": shared.ID, "remark": "share"}, &configShare)
+	sharer.mustPost("/plan-create", gin.H{"name": "shared", "remark": "shared"}, &sharedPlan)
+	sharer.mustPost("/plan-share-create", gin.H{"id": sharedPlan.ID, "remark": "share"}, &planShare)
+
+	owner := newClient(t)
+	registerAndLogin(t, owner, "duplicate-owner")
+	var config, plan idRes
+	owner.mustPost("/config-create", gin.H{
+		"name": "semester", "type": 1, "format": 1, "content": globalContent, "remark": "global",
+	}, &config)
+	owner.mustPost("/plan-create", gin.H{"name": "plan", "remark": "plan"}, &plan)
+
+	adds := []struct {
+		path string
+		req  gin.H
+	}{
+		{"/plan-add-config", gin.H{"planId": plan.ID, "configId": config.ID}},
+		{"/plan-add-share", gin.H{"planId": plan.ID, "configShareId": configShare.ID}},
+		{"/favor-config-add", gin.H{"id": configShare.ID}},
+		{"/favor-plan-add", gin.H{"id": planShare.ID}},
+	}
+	for _, add := range adds {
+		owner.mustPost(add.path, add.req, nil)
+		if code := owner.post(add.path, add.req, nil); code != http.StatusConflict {
+			t.Errorf("%s twice: status %d, want %d", add.path, code, http.StatusConflict)
+		}
+	}
+
+	var detail struct {
+		Configs []idRes `json:"configs"`
+		Shares  []idRes `json:"shares"`
+	}
+	owner.mustPost("/plan-get-by-id", gin.H{"id": plan.ID}, &detail)
+	if len(detail.Configs) != 1 || len(detail.Shares) != 1 {
+		t.Errorf("plan-get-by-id: %d configs and %d shares, want 1 and 1", len(detail.Configs), len(detail.Shares))
+	}
+	var favorConfigs struct {
+		Configs []idRes `json:"configs"`
+	}
+	owner.mustPost("/favor-config-get-list", gin.H{"count": 10}, &favorConfigs)
+	var favorPlans struct {
+		Plans []idRes `json:"plans"`
+	}
+	owner.mustPost("/favor-plan-get-list", gin.H{"count": 10}, &favorPlans)
+	if len(favorConfigs.Configs) != 1 || len(favorPlans.Plans) != 1 {
+		t.Errorf("favor lists: %d configs and %d plans, want 1 and 1", len(favorConfigs.Configs), len(favorPlans.Plans))
+	}
+}
+
+// a plan has no more than 30 tokens, revoke some to create more
+func TestPlanTokenCap(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "token-cap")
+	var plan idRes
+	owner.mustPost("/plan-create", gin.H{"name": "plan", "remark": "plan"}, &plan)
+
+	var token struct {
+		Token string `json:"token"`
+	}
+	for i := 0; i < 30; i++ {
+		owner.mustPost("/plan-create-token", gin.H{"id": plan.ID}, &token)
+	}
+	if code := owner.post("/plan-create-token", gin.H{"id": plan.ID}, nil); code != http.StatusBadRequest {
+		t.Errorf("the 31st plan-create-token: status %d, want %d", code, http.StatusBadRequest)
+	}
+	var list struct {
+		Count int64 `json:"count"`
+	}
+	owner.mustPost("/plan-get-token-list", gin.H{"id": plan.ID}, &list)
+	if list.Count != 30 {
+		t.Errorf("plan-get-token-list: %d tokens, want 30", list.Count)
+	}
+
+	owner.mustPost("/plan-revoke-token", gin.H{"token": token.Token}, nil)
+	owner.mustPost("/plan-create-token", gin.H{"id": plan.ID}, nil)
+}
@@ -0,0 +1,66 @@
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+)
+
+type probeRes struct {
+	Status string                          `json:"status"`
+	Data   map[string]dto.DependencyStatus `json:"data"`
+}
+
+func probe(t *testing.T, path string) (int, probeRes) {
+	t.Helper()
+	res, err := http.Get(serverURL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var body probeRes
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("%s: decode response: %v", path, err)
+	}
+	return res.StatusCode, body
+}
+
+// the probes are served outside of http basepath, readyz reports database and rpc server
+func TestHealthProbes(t *testing.T) {
+	res, err := http.Get(serverURL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var health struct {
+		Status string `json:"status"`
+		Data   string `json:"data"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&health)
+	res.Body.Close()
+	if err != nil || res.StatusCode != http.StatusOK || health.Data != "ok" {
+		t.Errorf("healthz: status %d, body %+v, %v", res.StatusCode, health, err)
+	}
+
+	// the rpc connection is established in background
+	var code int
+	var body probeRes
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		code, body = probe(t, "/readyz")
+		if code == http.StatusOK || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if code != http.StatusOK || body.Status != "ok" {
+		t.Fatalf("readyz: status %d, body %+v", code, body)
+	}
+	for _, name := range []string{"database", "rpc"} {
+		if body.Data[name].Status != "ok" {
+			t.Errorf("readyz: %s is %+v", name, body.Data[name])
+		}
+	}
+}
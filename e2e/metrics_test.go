@@ -0,0 +1,121 @@
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the metrics are served outside of http basepath in prometheus text format
+func TestMetrics(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "metrics-owner")
+	res := owner.get("/generate-by-plan-token?token="+url.QueryEscape(createPlanToken(t, owner)), nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("generate: status %d", res.StatusCode)
+	}
+
+	res, err := http.Get(serverURL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("metrics: status %d", res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("metrics: content type %q", ct)
+	}
+
+	for _, sample := range []string{
+		`csti_http_requests_total{method="GET",route="/api/generate-by-plan-token",status="200"} `,
+		`csti_http_request_duration_seconds_bucket{method="POST",route="/api/login",le="+Inf"} `,
+		`csti_db_query_duration_seconds_count{operation="select"} `,
+		`csti_rpc_generate_requests_total{code="OK"} `,
+		`csti_rpc_generate_duration_seconds_count{code="OK"} `,
+		"\ncsti_plan_generations_total ",
+		"\ncsti_generate_cache_misses_total ",
+		"\ncsti_active_sessions ",
+	} {
+		if !strings.Contains(string(body), sample) {
+			t.Errorf("metrics: no sample %s", sample)
+		}
+	}
+
+	// the cache counters are only exposed as metrics
+	res = owner.get("/generate-cache-stats", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("generate-cache-stats: status %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+
+	// plans don't split the series
+	if strings.Contains(string(body), "plan_id=") {
+		t.Error("metrics: labeled by plan")
+	}
+
+	// scraping is not counted
+	if strings.Contains(string(body), `route="/metrics"`) {
+		t.Error("metrics: scraping is counted")
+	}
+}
+
+// the generations of each plan are counted in database instead of labeled metrics
+func TestPlanGenerateCount(t *testing.T) {
+	useBuckets(t, noLimit{})
+	owner := newClient(t)
+	registerAndLogin(t, owner, "count-owner")
+	var config, plan, share idRes
+	owner.mustPost("/config-create", gin.H{
+		"name": "semester", "type": 1, "format": 1, "content": globalContent, "remark": "global",
+	}, &config)
+	owner.mustPost("/plan-create", gin.H{"name": "plan", "remark": "plan"}, &plan)
+	owner.mustPost("/plan-add-config", gin.H{"planId": plan.ID, "configId": config.ID}, nil)
+	owner.mustPost("/plan-share-create", gin.H{"id": plan.ID, "remark": "share"}, &share)
+	var token struct {
+		Token string `json:"token"`
+	}
+	owner.mustPost("/plan-create-token", gin.H{"id": plan.ID}, &token)
+
+	var before, after struct {
+		ModifyTime    string `json:"modifyTime"`
+		GenerateCount int64  `json:"generateCount"`
+	}
+	owner.mustPost("/plan-get-by-id", gin.H{"id": plan.ID}, &before)
+	if before.GenerateCount != 0 {
+		t.Errorf("plan-get-by-id: generateCount %d of new plan", before.GenerateCount)
+	}
+
+	generate := func(path string, header http.Header, want int) {
+		t.Helper()
+		res := owner.get(path, header)
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Fatalf("%s: status %d, want %d", path, res.StatusCode, want)
+		}
+	}
+	byToken := "/generate-by-plan-token?token=" + url.QueryEscape(token.Token)
+	generate(byToken, nil, http.StatusOK)
+	// served from cache
+	generate(byToken, nil, http.StatusOK)
+	generate(fmt.Sprintf("/generate-by-plan-share?shareId=%d", share.ID), nil, http.StatusOK)
+	// not modified is not a generation
+	generate(byToken, http.Header{"If-Modified-Since": {time.Now().UTC().Format(http.TimeFormat)}},
+		http.StatusNotModified)
+
+	owner.mustPost("/plan-get-by-id", gin.H{"id": plan.ID}, &after)
+	if after.GenerateCount != 3 {
+		t.Errorf("plan-get-by-id: generateCount %d, want 3", after.GenerateCount)
+	}
+	if after.ModifyTime != before.ModifyTime {
+		t.Errorf("plan-get-by-id: modifyTime changed from %s to %s by generation", before.ModifyTime, after.ModifyTime)
+	}
+}
@@ -0,0 +1,92 @@
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the owner previews the plan with unsaved configs, the envelope returned is the one sent to rpc server
+func TestPlanGeneratePreview(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "preview-owner")
+
+	var global, lesson, plan idRes
+	owner.mustPost("/config-create", gin.H{
+		"name": "semester", "type": 1, "format": 1, "content": `{"timezone": "UTC"}`, "remark": "global",
+	}, &global)
+	owner.mustPost("/config-create", gin.H{
+		"name": "algebra", "type": 2, "format": 1, "content": `{"name": "algebra"}`, "remark": "lesson",
+	}, &lesson)
+	owner.mustPost("/plan-create", gin.H{"name": "preview", "remark": "preview"}, &plan)
+	owner.mustPost("/plan-add-config", gin.H{"planId": plan.ID, "configId": global.ID}, nil)
+	owner.mustPost("/plan-add-config", gin.H{"planId": plan.ID, "configId": lesson.ID}, nil)
+
+	preview := func(req gin.H, want string) {
+		t.Helper()
+		var res struct {
+			Content  string `json:"content"`
+			Envelope string `json:"envelope"`
+		}
+		owner.mustPost("/plan-generate-preview", req, &res)
+		if res.Content != fakeCalendar {
+			t.Errorf("preview: content %q, want %q", res.Content, fakeCalendar)
+		}
+		if res.Envelope != generator.lastEnvelope() {
+			t.Errorf("preview: envelope %s, but %s sent to rpc server", res.Envelope, generator.lastEnvelope())
+		}
+		var got, wantValue interface{}
+		if err := json.Unmarshal([]byte(res.Envelope), &got); err != nil {
+			t.Fatalf("preview: invalid envelope %s", res.Envelope)
+		}
+		json.Unmarshal([]byte(want), &wantValue)
+		if !reflect.DeepEqual(got, wantValue) {
+			t.Errorf("preview: envelope %s, want %s", res.Envelope, want)
+		}
+	}
+
+	// the saved configs
+	preview(gin.H{"id": plan.ID}, `{"global": {"timezone": "UTC"}, "lessons": [{"name": "algebra"}]}`)
+
+	// replace a saved config and add a new one in toml
+	preview(gin.H{"id": plan.ID, "configs": []gin.H{
+		{"id": lesson.ID, "format": 1, "content": `{"name": "algebra II"}`},
+		{"type": 2, "format": 2, "content": `name = "physics"`},
+	}}, `{"global": {"timezone": "UTC"}, "lessons": [{"name": "algebra II"}, {"name": "physics"}]}`)
+
+	// the unsaved configs are not saved
+	var saved struct {
+		Content string `json:"content"`
+	}
+	owner.mustPost("/config-get-by-id", gin.H{"id": lesson.ID}, &saved)
+	if saved.Content != `{"name": "algebra"}` {
+		t.Errorf("config-get-by-id: content %s after preview", saved.Content)
+	}
+
+	calls := generator.calls()
+	for name, req := range map[string]gin.H{
+		"config not in plan": {"id": plan.ID, "configs": []gin.H{{"id": lesson.ID + 100, "format": 1, "content": `{}`}}},
+		"invalid content":    {"id": plan.ID, "configs": []gin.H{{"type": 2, "format": 1, "content": `[`}}},
+		"no global config":   {"id": plan.ID, "configs": []gin.H{{"id": global.ID, "format": 1, "content": `[]`}}},
+	} {
+		if code := owner.post("/plan-generate-preview", req, nil); code != http.StatusBadRequest {
+			t.Errorf("preview with %s: status %d, want %d", name, code, http.StatusBadRequest)
+		}
+	}
+
+	// only the owner could preview
+	other := newClient(t)
+	registerAndLogin(t, other, "preview-other")
+	if code := other.post("/plan-generate-preview", gin.H{"id": plan.ID}, nil); code != http.StatusBadRequest {
+		t.Errorf("preview by other user: status %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := newClient(t).post("/plan-generate-preview", gin.H{"id": plan.ID}, nil); code != http.StatusForbidden {
+		t.Errorf("preview by visitor: status %d, want %d", code, http.StatusForbidden)
+	}
+	if generator.calls() != calls {
+		t.Error("rpc server is called by rejected previews")
+	}
+}
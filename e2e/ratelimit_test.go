@@ -0,0 +1,138 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/ratelimit"
+)
+
+// a plan token is limited by its own bucket, the requests over burst are rejected with Retry-After
+func TestRateLimitGenerate(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "ratelimit-owner")
+	path := "/generate-by-plan-token?token=" + url.QueryEscape(createPlanToken(t, owner))
+
+	for i := 0; i < 5; i++ {
+		res := owner.get(path, nil)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("generate %d: status %d, want %d", i, res.StatusCode, http.StatusOK)
+		}
+	}
+
+	res := owner.get(path, nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("generate over burst: status %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	// refilled 1 token per minute
+	if retryAfter, err := strconv.Atoi(res.Header.Get("Retry-After")); err != nil || retryAfter < 1 || retryAfter > 60 {
+		t.Errorf("generate over burst: Retry-After %q, want in [1, 60]", res.Header.Get("Retry-After"))
+	}
+
+	other := "/generate-by-plan-token?token=" + url.QueryEscape(createPlanToken(t, owner))
+	res = owner.get(other, nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("generate by another token: status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+// burstStore cap the burst of every limit, so a test reach the limit in a few requests
+type burstStore struct {
+	*ratelimit.Memory
+	burst int
+}
+
+func (s burstStore) Take(key string, limit ratelimit.Limit) (bool, time.Duration, error) {
+	if limit.Burst > s.burst {
+		limit.Burst = s.burst
+	}
+	return s.Memory.Take(key, limit)
+}
+
+// useBuckets replace the buckets of rate limit until the test end,
+// so the test doesn't drain the buckets of 127.0.0.1 shared by other tests
+func useBuckets(t *testing.T, st ratelimit.Store) {
+	old := ratelimit.Buckets
+	ratelimit.Buckets = st
+	t.Cleanup(func() { ratelimit.Buckets = old })
+}
+
+// X-Forwarded-For is ignored from untrusted peers, rotating it doesn't get fresh buckets
+func TestRateLimitForwardedFor(t *testing.T) {
+	useBuckets(t, burstStore{ratelimit.NewMemory(), 2})
+
+	login := func(forwardedFor string) int {
+		body, _ := json.Marshal(gin.H{"username": "ratelimit-nobody", "password": "wrong", "tokenDuration": 1})
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/login", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.Header.Set("X-Real-IP", forwardedFor)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	for i := 0; i < 2; i++ {
+		if status := login(fmt.Sprintf("203.0.113.%d", i)); status == http.StatusTooManyRequests {
+			t.Fatalf("login %d: status %d before burst", i, status)
+		}
+	}
+	if status := login("203.0.113.99"); status != http.StatusTooManyRequests {
+		t.Fatalf("login with another X-Forwarded-For: status %d, want %d", status, http.StatusTooManyRequests)
+	}
+}
+
+// invalid plan tokens are limited by client IP, rotating them doesn't get fresh buckets
+func TestRateLimitBogusTokens(t *testing.T) {
+	useBuckets(t, ratelimit.NewMemory())
+	owner := newClient(t)
+	registerAndLogin(t, owner, "ratelimit-bogus")
+	subscribed := "/generate-by-plan-token?token=" + url.QueryEscape(createPlanToken(t, owner))
+	res := owner.get(subscribed, nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("generate by valid token: status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	for i := 0; i < 5; i++ {
+		res := owner.get(fmt.Sprintf("/generate-by-plan-token?token=bogus%d", i), nil)
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("generate by bogus token %d: status %d, want %d", i, res.StatusCode, http.StatusBadRequest)
+		}
+	}
+	res = owner.get("/generate-by-plan-token?token=bogus-over-burst", nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("generate by bogus token over burst: status %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+
+	// the token known valid is still limited by its own bucket
+	res = owner.get(subscribed, nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("generate by known token: status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	// while the unknown tokens are not looked up until the client IP is refilled
+	res = owner.get("/generate-by-plan-token?token="+url.QueryEscape(createPlanToken(t, owner)), nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("generate by unknown token: status %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+}
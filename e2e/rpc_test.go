@@ -0,0 +1,56 @@
+package e2e
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// transient failures are retried, and the circuit breaker open after consecutive failures
+// until the rpc server recover
+func TestGenerateCircuitBreaker(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "breaker-owner")
+	path := "/generate-by-plan-token?token=" + url.QueryEscape(createPlanToken(t, owner))
+
+	generator.failWith(codes.Unavailable)
+	defer generator.failWith(codes.OK)
+
+	// threshold is 2 and every failed call is retried once
+	for i := 0; i < 2; i++ {
+		calls := generator.calls()
+		res := owner.get(path, nil)
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK || res.StatusCode == http.StatusServiceUnavailable {
+			t.Fatalf("generate %d: status %d while rpc server failing", i, res.StatusCode)
+		}
+		if generator.calls() != calls+2 {
+			t.Errorf("generate %d: rpc called %d times, want 2", i, generator.calls()-calls)
+		}
+	}
+
+	calls := generator.calls()
+	res := owner.get(path, nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("generate with breaker open: status %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+	if res.Header.Get("Retry-After") == "" {
+		t.Error("generate with breaker open: no Retry-After")
+	}
+	if generator.calls() != calls {
+		t.Error("generate with breaker open: rpc server is called")
+	}
+
+	// the probe after cooldown close the breaker
+	generator.failWith(codes.OK)
+	time.Sleep(1100 * time.Millisecond)
+	res = owner.get(path, nil)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("generate after recovered: status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
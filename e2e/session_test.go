@@ -0,0 +1,90 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sessionDetail struct {
+	ID        int64  `json:"id"`
+	UserAgent string `json:"userAgent"`
+	IP        string `json:"ip"`
+	Current   bool   `json:"current"`
+}
+
+// listSessions return the sessions of the client's user and the ID of the current one
+func listSessions(t *testing.T, c *client) ([]sessionDetail, int64) {
+	t.Helper()
+	var res struct {
+		Sessions []sessionDetail `json:"sessions"`
+	}
+	c.mustPost("/session-list", gin.H{}, &res)
+	var current int64
+	for _, s := range res.Sessions {
+		if s.Current {
+			if current != 0 {
+				t.Fatalf("session-list: more than one current session in %+v", res.Sessions)
+			}
+			current = s.ID
+		}
+	}
+	if current == 0 {
+		t.Fatalf("session-list: no current session in %+v", res.Sessions)
+	}
+	return res.Sessions, current
+}
+
+// a user lists and revokes the login sessions of the own only
+func TestSessions(t *testing.T) {
+	laptop := newClient(t)
+	registerAndLogin(t, laptop, "session-user")
+	phone := newClient(t)
+	phone.mustPost("/login", gin.H{"username": "session-user", "password": "session-user-pass", "tokenDuration": 1}, nil)
+
+	sessions, laptopID := listSessions(t, laptop)
+	if len(sessions) != 2 {
+		t.Fatalf("session-list: %d sessions, want 2", len(sessions))
+	}
+	for _, s := range sessions {
+		if s.IP != "127.0.0.1" || s.UserAgent == "" {
+			t.Errorf("session-list: session %+v not recorded the client", s)
+		}
+	}
+	_, phoneID := listSessions(t, phone)
+	if phoneID == laptopID {
+		t.Fatalf("session-list: both clients are the current session %d", laptopID)
+	}
+
+	// revoking the session of another user does nothing
+	other := newClient(t)
+	registerAndLogin(t, other, "session-other")
+	if code := other.post("/session-revoke", gin.H{"id": laptopID}, nil); code != http.StatusBadRequest {
+		t.Errorf("session-revoke by other user: status %d, want %d", code, http.StatusBadRequest)
+	}
+	if sessions, _ = listSessions(t, laptop); len(sessions) != 2 {
+		t.Errorf("session-list after revoked by other user: %d sessions, want 2", len(sessions))
+	}
+
+	laptop.mustPost("/session-revoke", gin.H{"id": phoneID}, nil)
+	if code := phone.post("/session-list", gin.H{}, nil); code != http.StatusForbidden {
+		t.Errorf("session-list by revoked session: status %d, want %d", code, http.StatusForbidden)
+	}
+	if sessions, _ = listSessions(t, laptop); len(sessions) != 1 || sessions[0].ID != laptopID {
+		t.Errorf("session-list after revoke: %+v, want only %d", sessions, laptopID)
+	}
+	if code := laptop.post("/session-revoke", gin.H{"id": phoneID}, nil); code != http.StatusBadRequest {
+		t.Errorf("session-revoke twice: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	// logout-all revoke every session of the user, but not the others'
+	phone.mustPost("/login", gin.H{"username": "session-user", "password": "session-user-pass", "tokenDuration": 1}, nil)
+	laptop.mustPost("/logout-all", gin.H{}, nil)
+	for name, c := range map[string]*client{"laptop": laptop, "phone": phone} {
+		if code := c.post("/session-list", gin.H{}, nil); code != http.StatusForbidden {
+			t.Errorf("session-list by %s after logout-all: status %d, want %d", name, code, http.StatusForbidden)
+		}
+	}
+	listSessions(t, other)
+}
@@ -0,0 +1,68 @@
+package e2e
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// the trace in request is continued by the spans of request, SQL statements and rpc call,
+// and sent to rpc server
+func TestTracing(t *testing.T) {
+	owner := newClient(t)
+	registerAndLogin(t, owner, "tracing-owner")
+	path := "/generate-by-plan-token?token=" + url.QueryEscape(createPlanToken(t, owner))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	res := owner.get(path, http.Header{"Traceparent": {"00-" + traceID + "-00f067aa0ba902b7-01"}})
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("generate: status %d", res.StatusCode)
+	}
+
+	var request, rpcCall sdktrace.ReadOnlySpan
+	var statements []sdktrace.ReadOnlySpan
+	for _, span := range spans.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			continue
+		}
+		switch {
+		case span.SpanKind() == trace.SpanKindServer:
+			request = span
+		case strings.HasPrefix(span.Name(), "db."):
+			statements = append(statements, span)
+		case strings.HasSuffix(span.Name(), "/jsonGenerate"):
+			rpcCall = span
+		}
+	}
+
+	if request == nil {
+		t.Fatal("generate: no span of request in the trace")
+	}
+	if request.Name() != "GET /api/generate-by-plan-token" {
+		t.Errorf("generate: span of request named %q", request.Name())
+	}
+	if len(statements) == 0 {
+		t.Error("generate: no span of SQL statement in the trace")
+	}
+	for _, span := range statements {
+		if span.Parent().SpanID() != request.SpanContext().SpanID() {
+			t.Errorf("generate: span %s is not child of request", span.Name())
+		}
+	}
+	if rpcCall == nil {
+		t.Fatal("generate: no span of rpc call in the trace")
+	}
+	if rpcCall.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Error("generate: span of rpc call is not child of request")
+	}
+
+	want := "00-" + traceID + "-" + rpcCall.SpanContext().SpanID().String() + "-01"
+	if got := generator.lastTraceparent(); got != want {
+		t.Errorf("generate: traceparent sent to rpc server %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/validation"
+)
+
+// with config-schema, the problem options are responded as field errors and the config is not saved
+func TestConfigSchemaErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	schema := `{"lesson": {"name": {"type": "string", "required": true}, "schedule": {"type": "array", "required": true}}}`
+	if err := os.WriteFile(path, []byte(schema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := validation.Init(path); err != nil {
+		t.Fatal(err)
+	}
+	defer validation.Init("")
+
+	owner := newClient(t)
+	registerAndLogin(t, owner, "schema-owner")
+
+	body, _ := json.Marshal(gin.H{
+		"name": "algebra", "type": 2, "format": 1, "content": `{"nmae": "x", "schedule": 1}`, "remark": "lesson",
+	})
+	res, err := owner.http.Post(baseURL+"/config-create", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var envelope struct {
+		Status string               `json:"status"`
+		Data   dto.ConfigInvalidRes `json:"data"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&envelope); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadRequest || envelope.Status != "bad" {
+		t.Fatalf("config-create: status %d %s, want %d bad", res.StatusCode, envelope.Status, http.StatusBadRequest)
+	}
+	want := []dto.ConfigFieldError{
+		{Field: "name", Message: "required field is missing"},
+		{Field: "schedule", Message: "should be array"},
+	}
+	if len(envelope.Data.Errors) != len(want) {
+		t.Fatalf("config-create: errors %+v, want %+v", envelope.Data.Errors, want)
+	}
+	for i := range want {
+		if envelope.Data.Errors[i] != want[i] {
+			t.Errorf("config-create: error %d %+v, want %+v", i, envelope.Data.Errors[i], want[i])
+		}
+	}
+
+	var list dto.ConfigGetListRes
+	owner.mustPost("/config-get-list", gin.H{"sortBy": "id", "offset": 0, "count": 30}, &list)
+	if len(list.Configs) != 0 {
+		t.Errorf("config-get-list: %d configs saved, want none", len(list.Configs))
+	}
+}
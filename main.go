@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/cache"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/db"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/metrics"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/middlewares"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/password"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/routers"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/rpc"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/server"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store/sqlstore"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/tracing"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/validation"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,19 +30,29 @@ func main() {
 		}
 	}
 
+	config.FillDefault()
+	if err = initLogger(config.LogFormat, config.LogLevel); err != nil {
+		logrus.Fatal(err)
+	}
 	config.LogCurrentConfig()
 
 	if err = config.ValidParamCombination(); err != nil {
 		logrus.Fatal(err)
 	}
 
-	// just initialize database or start server
+	// just initialize database, migrate database or start server
 	if config.InitDatabase {
 		if err = db.InitDatabase(); err != nil {
 			logrus.Fatal(err)
 		}
 		logrus.Info("database initialized")
 		return
+	} else if config.IsMigrate() {
+		if err = db.MigrateDatabase(config.MigrateTo, config.MigrateDryRun); err != nil {
+			logrus.Fatal(err)
+		}
+		logrus.Info("database migrated")
+		return
 	} else {
 		if err = db.Init(); err != nil {
 			logrus.Fatalf("failed to connect to database. detail: %s", err.Error())
@@ -41,19 +61,72 @@ func main() {
 		}
 	}
 
-	err = rpc.Init()
-	if err != nil {
-		logrus.Fatalf("Could not establish connection to %s. Please check the PRC server status. detail: %s",
-			config.RPCTarget, err.Error())
-	} else {
-		logrus.Info("rpc server connected")
+	if err = tracing.Init(); err != nil {
+		logrus.Fatalf("failed to initialize %s trace exporter. detail: %s", config.TraceExporter, err.Error())
+	}
+
+	// the connection is established in background, the rpc server is not required to be up
+	if err = rpc.Init(); err != nil {
+		logrus.Fatalf("failed to initialize rpc client of %s. detail: %s", config.RPCTarget, err.Error())
+	}
+
+	st := sqlstore.New(db.DB)
+
+	metrics.Init(st)
+	cache.Init(config.GenerateCacheSize)
+	if err = validation.Init(config.ConfigSchema); err != nil {
+		logrus.Fatal(err)
+	}
+	if config.TokenSweepInterval > 0 {
+		middlewares.StartTokenSweeper(st.Tokens, time.Duration(config.TokenSweepInterval)*time.Minute)
+	}
+	if err = password.Init(config.PasswordHasher, config.PasswordBcryptCost, uint32(config.PasswordArgon2Memory),
+		uint32(config.PasswordArgon2Time), uint8(config.PasswordArgon2Threads)); err != nil {
+		logrus.Fatal(err)
 	}
 
 	logrus.Info("starting rest server...")
 
 	// keep this initialize order!
 	server.Init()
-	middlewares.Init(server.Engine)
-	routers.Init(server.Engine)
-	server.Run()
+	middlewares.Init(server.Engine, st)
+	routers.Init(server.Engine, st)
+	err = server.Run()
+
+	// the in-flight requests are finished, release the connections
+	if closeErr := rpc.Close(); closeErr != nil {
+		logrus.Error(closeErr)
+	}
+	if closeErr := db.DB.Close(); closeErr != nil {
+		logrus.Error(closeErr)
+	}
+	// flush the spans of the last requests
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if closeErr := tracing.Shutdown(ctx); closeErr != nil {
+		logrus.Errorf("failed to flush spans. detail: %s", closeErr.Error())
+	}
+	cancel()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	logrus.Info("rest server stopped")
+}
+
+// set the format and level of logrus, the access log of requests is written with it too
+func initLogger(format string, level string) error {
+	switch format {
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	case "text":
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	default:
+		return fmt.Errorf("unsupported log format: %s", format)
+	}
+
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(lvl)
+	return nil
 }
@@ -0,0 +1,128 @@
+// Package metrics collect the counters and latencies of rest server, and expose them
+// in prometheus text exposition format.
+package metrics
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/cache"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// LatencyBuckets is the upper bounds in seconds of latency histograms
+var LatencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// registry hold the metrics of rest server, and the runtime metrics of go and the process
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+func init() {
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+var (
+	// HTTPRequests count the handled requests, route is the path registered to gin
+	// instead of the requested one, so the path parameters won't split the series
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "csti_http_requests_total",
+		Help: "Number of http requests handled.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "csti_http_request_duration_seconds",
+		Help:    "Latency of handling http requests.",
+		Buckets: LatencyBuckets,
+	}, []string{"method", "route"})
+
+	// DBQueryDuration is the latency of statements, operation is its first keyword, e: "select"
+	DBQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "csti_db_query_duration_seconds",
+		Help:    "Latency of database statements.",
+		Buckets: LatencyBuckets,
+	}, []string{"operation"})
+
+	// RPCGenerateRequests count the generate calls by grpc status code,
+	// code is "CircuitOpen" if rejected by the circuit breaker without calling rpc server
+	RPCGenerateRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "csti_rpc_generate_requests_total",
+		Help: "Number of generate calls to rpc server.",
+	}, []string{"code"})
+
+	// RPCGenerateDuration is the latency of generate calls including retries
+	RPCGenerateDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "csti_rpc_generate_duration_seconds",
+		Help:    "Latency of generate calls to rpc server.",
+		Buckets: LatencyBuckets,
+	}, []string{"code"})
+
+	// PlanGenerations count the generate results served, including the cached ones. It is not
+	// partitioned by plan, which would create a series for every plan ever generated,
+	// the count of each plan is kept in database and responded by plan-get-by-id instead
+	PlanGenerations = factory.NewCounter(prometheus.CounterOpts{
+		Name: "csti_plan_generations_total",
+		Help: "Number of generate results served.",
+	})
+)
+
+// Init register the metrics read from stores on each scrape, call it once
+func Init(st *store.Store) {
+	registry.MustRegister(&storeGauge{
+		desc: prometheus.NewDesc("csti_active_sessions", "Number of unexpired login sessions.", nil, nil),
+		read: func() (float64, error) {
+			n, err := st.Tokens.CountActiveSessions()
+			return float64(n), err
+		},
+	})
+	factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "csti_generate_cache_hits_total",
+		Help: "Number of generate results served from cache.",
+	}, func() float64 { return float64(cache.GetStats().Hits) })
+	factory.NewCounterFunc(prometheus.CounterOpts{
+		Name: "csti_generate_cache_misses_total",
+		Help: "Number of generate results not found in cache.",
+	}, func() float64 { return float64(cache.GetStats().Misses) })
+}
+
+// storeGauge is a gauge read from store on each scrape, it is omitted from the scrape
+// if the store fails, while the others are still served
+type storeGauge struct {
+	desc *prometheus.Desc
+	read func() (float64, error)
+}
+
+func (g *storeGauge) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *storeGauge) Collect(ch chan<- prometheus.Metric) {
+	v, err := g.read()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(g.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, v)
+}
+
+// Operation return the first keyword of SQL statement in lower case, used as the operation label
+func Operation(query string) string {
+	query = strings.TrimSpace(query)
+	if i := strings.IndexAny(query, " \t\r\n("); i >= 0 {
+		query = query[:i]
+	}
+	return strings.ToLower(query)
+}
+
+// Handler serve all registered metrics, the failed ones are logged and skipped
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		ErrorLog:      logrus.StandardLogger(),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
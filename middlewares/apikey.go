@@ -0,0 +1,91 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/utils"
+)
+
+// scopes of api key
+const (
+	ScopeRead    = "read"
+	ScopeConfigs = "configs"
+	ScopePlans   = "plans"
+	ScopeAdmin   = "admin"
+)
+
+// Scopes contains all available scopes of api key
+var Scopes = []string{ScopeRead, ScopeConfigs, ScopePlans, ScopeAdmin}
+
+// prefix of every api key, make it easy to recognize in scripts
+const apiKeyPrefix = "csti_"
+
+// add Key.UserID and Key.Scopes in gin.Context base on request's bearer token,
+// return false if the request doesn't carry a bearer token
+func verifyAPIKey(c *gin.Context, tokens store.TokenStore) bool {
+	auth := c.GetHeader("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	key := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+
+	hash := apiKeyHash(key)
+	apiKey, err := tokens.GetAPIKey(hash[:])
+	if err == nil {
+		c.Set(Key.UserID, apiKey.UserID)
+		c.Set(Key.Scopes, strings.Split(apiKey.Scopes, ","))
+		if err = tokens.TouchAPIKey(apiKey.ID); err != nil {
+			Logger(c).Error(err.Error())
+		}
+		Logger(c).Debugf("verified by api key %v", apiKey.ID)
+	} else if err != store.ErrNotFound {
+		Logger(c).Error(err.Error())
+	} else {
+		Logger(c).Info("invalid api key")
+	}
+	return true
+}
+
+// RegisterAPIKey create an api key of user with scopes, return the id and the key,
+// only the hash of key is stored so the key could not be shown again.
+//
+// expireDays is the valid period in days, 0 means never expire
+func RegisterAPIKey(tokens store.TokenStore, userID int64, name string, scopes []string,
+	expireDays int) (int64, string, error) {
+	key := apiKeyPrefix + utils.GenerateToken()
+	hash := apiKeyHash(key)
+
+	id, err := tokens.CreateAPIKey(userID, name, hash[:], key[:len(apiKeyPrefix)+4],
+		strings.Join(scopes, ","), expireDays)
+	if err != nil {
+		return 0, "", err
+	}
+	return id, key, nil
+}
+
+// HasScope return true if the request is allowed to access with scope.
+// Request authorized by cookie has all scopes, while api key need the exact scope or admin,
+// and every api key could read.
+func HasScope(c *gin.Context, scope string) bool {
+	scopesInterface, exist := c.Get(Key.Scopes)
+	if !exist {
+		return true
+	}
+	if scope == ScopeRead {
+		return true
+	}
+	for _, s := range scopesInterface.([]string) {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// api key is random enough, so bare sha256 is safe here
+func apiKeyHash(key string) [32]byte {
+	return sha256.Sum256([]byte(key))
+}
@@ -1,17 +1,25 @@
 package middlewares
 
 import (
-	"database/sql"
+	"net/http"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
-	"github.com/leafee98/class-schedule-to-icalendar-restserver/db"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/utils"
 	"github.com/sirupsen/logrus"
 )
 
 // KeyStruct is the words used as keys setted in gin.Context
 type KeyStruct struct {
-	UserID string
+	UserID    string
+	SessionID string
+
+	// Scopes is only set when the request is authorized by api key
+	Scopes string
+
+	RequestID string
 }
 
 // Key stored the key values setted in gin.Context
@@ -19,59 +27,99 @@ var Key KeyStruct
 
 func init() {
 	Key = KeyStruct{
-		UserID: "userID",
+		UserID:    "userID",
+		SessionID: "sessionID",
+		Scopes:    "scopes",
+		RequestID: "requestID",
 	}
 
 	registerMiddleware(verifyUser)
+	// the user is known after verifyUser
+	registerMiddleware(rateLimit)
 }
 
-// add Key.UserID's valuein gin.Context base on request's token
-func verifyUser(c *gin.Context) {
-	v, err := c.Cookie("token")
-	if err == nil {
-		var userID int64
-		row := db.DB.QueryRow("select c_user_id from t_login_token where c_token = ?", v)
-		err := row.Scan(&userID)
+// add Key.UserID's valuein gin.Context base on request's token,
+// expired token is treated as unauthorized.
+// api key in bearer token take precedence over token in cookie.
+func verifyUser(st *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokens := st.WithContext(c.Request.Context()).Tokens
+		if verifyAPIKey(c, tokens) {
+			return
+		}
 
+		v, err := c.Cookie("token")
 		if err == nil {
-			c.Set(Key.UserID, userID)
-			logrus.Infof("userID=%v verified", userID)
-			return
-		} else if err != sql.ErrNoRows {
-			logrus.Error(err.Error())
+			session, err := tokens.GetSession(v)
+
+			if err == nil {
+				c.Set(Key.UserID, session.UserID)
+				c.Set(Key.SessionID, session.ID)
+				if err := tokens.TouchSession(session.ID); err != nil {
+					Logger(c).Error(err.Error())
+				} else if session.Renew {
+					// the token is renewed, so does the cookie
+					c.SetSameSite(http.SameSiteStrictMode)
+					c.SetCookie("token", v, 3600*24*session.Duration, "/", "", false, false)
+				}
+				Logger(c).Debug("verified by session")
+				return
+			} else if err != store.ErrNotFound {
+				Logger(c).Error(err.Error())
+			}
 		}
+		Logger(c).Debug("unauthorized visitor")
 	}
-	logrus.Info("unauthorized visitor")
 }
 
 // RegisterToken will add an random token and userId in userTokenMap, and return the token,
 // so the middleware could add userId info from token to gin.Context per HTTP request later.
 // the token will be uuid string compatiable with rfc4122 removed dashes.
 //
-// duartion in hours
+// duartion in days
 //
-// Register new token will remove the older token.
-func RegisterToken(userID int64, duration int) string {
+// Each token is a session, the older tokens of the same user are kept,
+// userAgent and ip are recorded to help user recognize the session.
+// The token is useless if the session fails to be created, so is not returned.
+func RegisterToken(tokens store.TokenStore, userID int64, duration int, userAgent string, ip string) (string, error) {
 	token := utils.GenerateToken()
 
-	// remove the older token
-	_, err := db.DB.Exec("delete from t_login_token where c_user_id = ?", userID)
-	if err != nil {
-		logrus.Error(err.Error())
+	// insert new token into database
+	if err := tokens.CreateSession(userID, token, duration, truncate(userAgent, 256), truncate(ip, 64)); err != nil {
+		return "", err
 	}
+	return token, nil
+}
 
-	// insert new token into database
-	_, err = db.DB.Exec("insert into t_login_token (c_user_id, c_token, c_expire_time) values"+
-		" (?, ?, now() + interval ? day)",
-		userID, token, duration)
+// StartTokenSweeper remove expired tokens periodically in background,
+// so it does not depend on the event scheduler of database
+func StartTokenSweeper(tokens store.TokenStore, interval time.Duration) {
+	go func() {
+		for {
+			removeExpiredToken(tokens)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func removeExpiredToken(tokens store.TokenStore) {
+	affected, err := tokens.RemoveExpiredSessions()
 	if err != nil {
 		logrus.Error(err.Error())
+		return
+	}
+	if affected > 0 {
+		logrus.Infof("%d expired tokens removed", affected)
 	}
-
-	return token
 }
 
-// ExpireToken will remove the token in userTokenMap
-func ExpireToken(token string) {
-	db.DB.Exec("delete from t_login_token where c_token = ?", token)
+// truncate s to no more than max bytes, without splitting a multi-byte character
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
 }
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"errors"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+)
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"curl/7.88", 64, "curl/7.88"},
+		{"curl/7.88", 4, "curl"},
+		{"浏览器", 9, "浏览器"},
+		// a character of 3 bytes is never split
+		{"浏览器", 8, "浏览"},
+		{"浏览器", 4, "浏"},
+		{"浏览器", 2, ""},
+	}
+	for _, c := range cases {
+		got := truncate(c.s, c.max)
+		if got != c.want || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q, want %q", c.s, c.max, got, c.want)
+		}
+	}
+}
+
+// failingTokens is a TokenStore failing to create any session
+type failingTokens struct {
+	store.TokenStore
+}
+
+func (failingTokens) CreateSession(int64, string, int, string, string) error {
+	return errors.New("database is down")
+}
+
+func TestRegisterTokenError(t *testing.T) {
+	token, err := RegisterToken(failingTokens{}, 1, 1, "curl/7.88", "127.0.0.1")
+	if err == nil || token != "" {
+		t.Errorf("RegisterToken() = %q, %v, want an error and no token", token, err)
+	}
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trustedProxies are the reverse proxies whose forwarding headers are trusted, set by Init
+var trustedProxies []*net.IPNet
+
+// ClientIP return the IP of client sending the request. It is the peer of connection, unless the peer
+// is a trusted proxy, then the nearest untrusted address in X-Forwarded-For, or X-Real-IP.
+// gin's ClientIP is not used since it trusts the headers from anyone.
+func ClientIP(c *gin.Context) string {
+	return clientIP(c.Request, trustedProxies)
+}
+
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !isTrusted(ip, trusted) {
+		return ip
+	}
+
+	// each proxy appends the address it received from, so walk from the nearest one,
+	// the addresses before the first untrusted one may be forged by the client
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	hasForwarded := false
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		hasForwarded = true
+		ip = hop
+		if !isTrusted(hop, trusted) {
+			return hop
+		}
+	}
+	if hasForwarded {
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return ip
+}
+
+func isTrusted(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+)
+
+func TestClientIP(t *testing.T) {
+	config.TrustedProxies = "10.0.0.1, 192.168.0.0/24"
+	defer func() { config.TrustedProxies = "" }()
+	trusted, err := config.ParseTrustedProxies()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{"direct", "203.0.113.1:1234", nil, "", "203.0.113.1"},
+		{"forged by untrusted peer", "203.0.113.1:1234", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.1"},
+		{"trusted proxy", "10.0.0.1:1234", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:1234", []string{"198.51.100.1, 192.168.0.7"}, "", "198.51.100.1"},
+		{"forged before client", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1"}, "", "198.51.100.1"},
+		{"many headers", "10.0.0.1:1234", []string{"1.2.3.4", "198.51.100.1"}, "", "198.51.100.1"},
+		{"all trusted", "10.0.0.1:1234", []string{"192.168.0.8, 192.168.0.7"}, "", "192.168.0.8"},
+		{"real ip", "192.168.0.7:1234", nil, "198.51.100.1", "198.51.100.1"},
+		{"malformed", "10.0.0.1:1234", []string{"unknown"}, "also-unknown", "10.0.0.1"},
+		{"ipv6 peer", "[2001:db8::1]:1234", []string{"198.51.100.1"}, "", "2001:db8::1"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tc.peer
+		for _, v := range tc.forwarded {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if tc.realIP != "" {
+			r.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if got := clientIP(r, trusted); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	defer func() { config.TrustedProxies = "" }()
+	for _, invalid := range []string{"10.0.0", "10.0.0.0/33", "localhost"} {
+		config.TrustedProxies = "10.0.0.1," + invalid
+		if _, err := config.ParseTrustedProxies(); err == nil {
+			t.Errorf("expect %q refused", invalid)
+		}
+	}
+}
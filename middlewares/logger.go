@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/rpc"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carry the request ID in both request and response
+const RequestIDHeader = "X-Request-ID"
+
+// the incoming request ID longer than this is replaced
+const maxRequestIDLength = 128
+
+// Logger return the log entry carrying the request ID, user ID and trace ID of c,
+// handlers should log with it so the logs of one request could be found together
+func Logger(c *gin.Context) *logrus.Entry {
+	fields := logrus.Fields{}
+	if id, ok := c.Get(Key.RequestID); ok {
+		fields["request_id"] = id
+	}
+	if id, ok := c.Get(Key.UserID); ok {
+		fields["user_id"] = id
+	}
+	if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+	}
+	return logrus.WithFields(fields)
+}
+
+// requestLogger assign the request ID, honor the one in X-Request-ID of request,
+// and write the access log after the request handled.
+// The request ID is also sent to rpc server as metadata by the calls with the request context.
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		c.Set(Key.RequestID, id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(rpc.WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+
+		Logger(c).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+			"status":     c.Writer.Status(),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"ip":         ClientIP(c),
+		}).Info("request handled")
+	}
+}
+
+// only printable ascii without space is accepted, so the id won't break the log
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -1,16 +1,33 @@
 package middlewares
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+	"github.com/sirupsen/logrus"
+)
 
-var ms []gin.HandlerFunc = make([]gin.HandlerFunc, 0, 4)
+// Middleware create the gin middleware with the stores it depends on
+type Middleware func(st *store.Store) gin.HandlerFunc
+
+var ms []Middleware = make([]Middleware, 0, 4)
 
 // Init is used to initialzed middlewares with gin.Engine
-func Init(r *gin.Engine) {
+func Init(r *gin.Engine, st *store.Store) {
+	proxies, err := config.ParseTrustedProxies()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	trustedProxies = proxies
+
+	// the request ID is required by the logs of all later middlewares,
+	// and the trace context by the statements they run
+	r.Use(requestTracer(), requestLogger(), requestMetrics())
 	for _, m := range ms {
-		r.Use(m)
+		r.Use(m(st))
 	}
 }
 
-func registerMiddleware(m gin.HandlerFunc) {
+func registerMiddleware(m Middleware) {
 	ms = append(ms, m)
 }
@@ -0,0 +1,171 @@
+package middlewares
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/ratelimit"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+)
+
+// route groups sharing a rate limit
+const (
+	rateLimitAuth     = "auth"
+	rateLimitGenerate = "generate"
+	rateLimitAPI      = "api"
+)
+
+// rateLimit abort the request with 429 and Retry-After if its client run out of the
+// token bucket of route group. The requests matching no route are not limited.
+// It should be registered after verifyUser to limit by user.
+func rateLimit(st *store.Store) gin.HandlerFunc {
+	limits := map[string]ratelimit.Limit{
+		rateLimitAuth:     ratelimit.PerMinute(config.RateLimitAuth, config.RateLimitAuthBurst),
+		rateLimitGenerate: ratelimit.PerMinute(config.RateLimitGenerate, config.RateLimitGenerateBurst),
+		rateLimitAPI:      ratelimit.PerMinute(config.RateLimitAPI, config.RateLimitAPIBurst),
+	}
+	basepath := strings.TrimSuffix(config.HTTPBasepath, "/")
+
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		group, key := rateLimitKey(c, st, strings.TrimPrefix(route, basepath))
+		limit := limits[group]
+		if limit.Rate <= 0 {
+			return
+		}
+
+		ok, retryAfter, err := ratelimit.Buckets.Take(group+"/"+key, limit)
+		if err != nil {
+			// a broken shared store should not take down the whole server
+			Logger(c).Error(err.Error())
+			return
+		}
+		if group == rateLimitGenerate && strings.HasPrefix(key, "ip:") {
+			planTokens.block(key, retryAfter, ok)
+		}
+		if !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			Logger(c).Infof("rate limited in group %s", group)
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests,
+				dto.NewResponseBad(fmt.Sprintf("too many requests, retry after %ds", seconds)))
+		}
+	}
+}
+
+// rateLimitKey return the route group of path and the key of client in the group
+func rateLimitKey(c *gin.Context, st *store.Store, path string) (string, string) {
+	ip := "ip:" + ClientIP(c)
+	switch path {
+	case "/login", "/register":
+		return rateLimitAuth, ip
+	case "/generate-by-plan-token":
+		// each subscribed calendar is limited by its token, wherever it is fetched from,
+		// while the invalid tokens are limited by client IP, so guessing tokens don't get fresh buckets.
+		// The token is looked up only if not known valid and the client IP has tokens left
+		if token := c.Query("token"); token != "" {
+			if planTokens.valid(token) {
+				return rateLimitGenerate, "token:" + token
+			}
+			if planTokens.blocked(ip) {
+				return rateLimitGenerate, ip
+			}
+			_, err := st.WithContext(c.Request.Context()).Plans.TokenPlan(token)
+			if err == nil {
+				planTokens.add(token)
+				return rateLimitGenerate, "token:" + token
+			} else if err != store.ErrNotFound {
+				Logger(c).Error(err.Error())
+			}
+		}
+		return rateLimitGenerate, ip
+	case "/generate-by-plan-share":
+		return rateLimitGenerate, ip
+	}
+	if userID, exist := c.Get(Key.UserID); exist {
+		return rateLimitAPI, fmt.Sprintf("user:%v", userID)
+	}
+	return rateLimitAPI, ip
+}
+
+// planTokenTTL is how long a plan token found valid is keyed by itself without looking up again.
+// A revoked token is still rejected by the router, only the bucket charged is affected
+const planTokenTTL = 10 * time.Minute
+
+// planTokenCacheSize bound the entries of planTokenCache, the expired ones are dropped when full,
+// and all are dropped if still full
+const planTokenCacheSize = 10000
+
+// planTokenCache remember the plan tokens found valid, and the client IPs ran out of the bucket
+// until refilled, so neither the subscribed calendars nor the clients guessing tokens look up
+// database on every request
+type planTokenCache struct {
+	mu         sync.Mutex
+	tokens     map[string]time.Time
+	blockedIPs map[string]time.Time
+}
+
+var planTokens = &planTokenCache{tokens: make(map[string]time.Time), blockedIPs: make(map[string]time.Time)}
+
+func (p *planTokenCache) valid(token string) bool {
+	return p.get(p.tokens, token)
+}
+
+func (p *planTokenCache) add(token string) {
+	p.set(p.tokens, token, planTokenTTL)
+}
+
+func (p *planTokenCache) blocked(ip string) bool {
+	return p.get(p.blockedIPs, ip)
+}
+
+// block the client IP for d if its bucket is empty, otherwise unblock it,
+// the bucket may be refilled earlier by another server or a swapped store
+func (p *planTokenCache) block(ip string, d time.Duration, ok bool) {
+	if !ok {
+		p.set(p.blockedIPs, ip, d)
+		return
+	}
+	p.mu.Lock()
+	delete(p.blockedIPs, ip)
+	p.mu.Unlock()
+}
+
+// get return true if key is in m and not expired
+func (p *planTokenCache) get(m map[string]time.Time, key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expire, exist := m[key]
+	return exist && time.Now().Before(expire)
+}
+
+// set key in m expiring after d
+func (p *planTokenCache) set(m map[string]time.Time, key string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if len(m) >= planTokenCacheSize {
+		for k, expire := range m {
+			if !now.Before(expire) {
+				delete(m, k)
+			}
+		}
+		if len(m) >= planTokenCacheSize {
+			for k := range m {
+				delete(m, k)
+			}
+		}
+	}
+	m[key] = now.Add(d)
+}
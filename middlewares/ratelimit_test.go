@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/ratelimit"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+)
+
+// countingPlans is a PlanStore whose only valid plan token is "valid", counting the lookups
+type countingPlans struct {
+	store.PlanStore
+	lookups int
+}
+
+func (p *countingPlans) TokenPlan(token string) (int64, error) {
+	p.lookups++
+	if token == "valid" || token == "valid-too" {
+		return 1, nil
+	}
+	return 0, store.ErrNotFound
+}
+
+func TestRateLimitPlanToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.HTTPBasepath = "/api"
+	config.RateLimitGenerate, config.RateLimitGenerateBurst = 1, 2
+	oldBuckets, oldTokens := ratelimit.Buckets, planTokens
+	ratelimit.Buckets = ratelimit.NewMemory()
+	planTokens = &planTokenCache{tokens: make(map[string]time.Time), blockedIPs: make(map[string]time.Time)}
+	defer func() {
+		config.HTTPBasepath = ""
+		config.RateLimitGenerate, config.RateLimitGenerateBurst = 0, 0
+		ratelimit.Buckets, planTokens = oldBuckets, oldTokens
+	}()
+
+	plans := &countingPlans{}
+	st := &store.Store{Plans: plans}
+	st.WithContext = func(ctx context.Context) *store.Store { return st }
+	engine := gin.New()
+	engine.GET("/api/generate-by-plan-token", rateLimit(st), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	steps := []struct {
+		name        string
+		token       string
+		want        int
+		wantLookups int
+	}{
+		{"valid token", "valid", http.StatusOK, 1},
+		// known valid, not looked up again
+		{"valid token again", "valid", http.StatusOK, 1},
+		{"valid token over burst", "valid", http.StatusTooManyRequests, 1},
+		{"bogus token", "bogus1", http.StatusOK, 2},
+		{"another bogus token", "bogus2", http.StatusOK, 3},
+		{"bogus token over burst", "bogus3", http.StatusTooManyRequests, 4},
+		// the client IP ran out of bucket, its unknown tokens are rejected without lookup
+		{"bogus token after blocked", "bogus4", http.StatusTooManyRequests, 4},
+		{"unknown valid token after blocked", "valid-too", http.StatusTooManyRequests, 4},
+	}
+	for _, s := range steps {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/generate-by-plan-token?token="+s.token, nil)
+		req.RemoteAddr = "203.0.113.1:1234"
+		engine.ServeHTTP(w, req)
+		if w.Code != s.want || plans.lookups != s.wantLookups {
+			t.Errorf("%s: status %d, %d lookups, want %d, %d", s.name, w.Code, plans.lookups, s.want, s.wantLookups)
+		}
+	}
+}
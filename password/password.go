@@ -0,0 +1,77 @@
+package password
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+)
+
+// Hasher hash the plain password to a string prefixed with its algorithm, e: $argon2id$...
+type Hasher interface {
+	// Hash return the hashed password with algorithm prefix and parameters
+	Hash(plain string) (string, error)
+
+	// Verify check the plain password with the hashed one produced by this hasher
+	Verify(plain string, hashed string) (bool, error)
+
+	// Match return true if the hashed password is produced by this hasher
+	Match(hashed string) bool
+
+	// NeedRehash return true if the hashed password use parameters other than current
+	NeedRehash(hashed string) bool
+}
+
+// Default is the Hasher used to hash new password
+var Default Hasher = NewArgon2id(64*1024, 1, 4)
+
+// all known hashers, used to verify password hashed by hasher other than Default
+var hashers []Hasher = []Hasher{&Argon2id{}, &Bcrypt{}}
+
+// Init select the Default Hasher by name with cost parameters.
+// available names: "argon2id", "bcrypt"
+func Init(name string, bcryptCost int, argon2Memory uint32, argon2Time uint32, argon2Threads uint8) error {
+	switch name {
+	case "argon2id":
+		Default = NewArgon2id(argon2Memory, argon2Time, argon2Threads)
+	case "bcrypt":
+		Default = NewBcrypt(bcryptCost)
+	default:
+		return fmt.Errorf("unsupported password hasher: %s", name)
+	}
+	return nil
+}
+
+// Hash hash the plain password with Default Hasher
+func Hash(plain string) (string, error) {
+	return Default.Hash(plain)
+}
+
+// Verify check the plain password with the hashed one stored in database,
+// needRehash is true when the password should be hashed again with Default Hasher,
+// such as the legacy unsalted sha256 hash.
+func Verify(plain string, stored []byte) (ok bool, needRehash bool, err error) {
+	hashed := string(stored)
+	if Default.Match(hashed) {
+		ok, err = Default.Verify(plain, hashed)
+		return ok, ok && Default.NeedRehash(hashed), err
+	}
+	for _, h := range hashers {
+		if h.Match(hashed) {
+			ok, err = h.Verify(plain, hashed)
+			return ok, ok, err
+		}
+	}
+	// the legacy hash is binary, it may start with "$" too
+	if len(stored) == sha256.Size {
+		return verifyLegacy(plain, stored), true, nil
+	}
+	return false, false, errors.New("unknown password hash algorithm")
+}
+
+// the legacy password is bare sha256 without prefix stored in binary(32),
+// the hashes with prefix are always longer
+func verifyLegacy(plain string, stored []byte) bool {
+	sum := sha256.Sum256([]byte(plain))
+	return subtle.ConstantTimeCompare(sum[:], stored) == 1
+}
@@ -0,0 +1,35 @@
+package password
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// the legacy sha256 hash starting with "$" is not mistaken for a hash with algorithm prefix
+func TestVerifyLegacyStartingWithDollar(t *testing.T) {
+	var plain string
+	var sum [sha256.Size]byte
+	for i := 0; ; i++ {
+		plain = fmt.Sprintf("password-%d", i)
+		if sum = sha256.Sum256([]byte(plain)); sum[0] == '$' {
+			break
+		}
+	}
+
+	ok, needRehash, err := Verify(plain, sum[:])
+	if err != nil || !ok || !needRehash {
+		t.Errorf("Verify(%q) = %t, %t, %v, want true, true, nil", plain, ok, needRehash, err)
+	}
+
+	ok, _, err = Verify(plain+"-wrong", sum[:])
+	if err != nil || ok {
+		t.Errorf("Verify wrong password = %t, %v, want false, nil", ok, err)
+	}
+}
+
+func TestVerifyUnknownAlgorithm(t *testing.T) {
+	if _, _, err := Verify("password", []byte("$unknown$salt$hash")); err == nil {
+		t.Error("Verify unknown algorithm: no error")
+	}
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is the interval to remove the full buckets, which are the same as new ones
+const sweepInterval = time.Minute
+
+// Memory is an in-process Store, the limits are not shared with other rest servers
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+
+	// full is the time the bucket is refilled to burst
+	full time.Time
+}
+
+// NewMemory create an empty Memory store
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// Take take a token from the bucket of key, never fail
+func (m *Memory) Take(key string, limit Limit) (bool, time.Duration, error) {
+	if limit.Rate <= 0 {
+		return true, 0, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	burst := float64(limit.Burst)
+	b, exist := m.buckets[key]
+	if !exist {
+		b = &bucket{tokens: burst, updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.updated).Seconds() * limit.Rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.updated = now
+
+	ok := b.tokens >= 1
+	if ok {
+		b.tokens--
+	}
+	b.full = now.Add(seconds((burst - b.tokens) / limit.Rate))
+	if !ok {
+		return false, seconds((1 - b.tokens) / limit.Rate), nil
+	}
+	return true, 0, nil
+}
+
+// sweep remove the full buckets once per sweepInterval, so the idle clients don't
+// hold memory. Call it with mu locked
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,28 @@
+// Package ratelimit limit the requests of clients by token buckets
+package ratelimit
+
+import "time"
+
+// Limit is a token bucket refilled Rate tokens per second up to Burst tokens,
+// each request take one token. Zero Rate means no limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute return the limit of n requests per minute allowing burst requests at once
+func PerMinute(n int, burst int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// Store keep the token buckets of clients
+type Store interface {
+	// Take take a token from the bucket of key, a new bucket is full.
+	// Return false and the time until next token if the bucket is empty
+	Take(key string, limit Limit) (bool, time.Duration, error)
+}
+
+// Buckets is the store used by the rate limit middleware, replace it with a shared store,
+// e: redis, to limit the clients across many rest servers.
+// Default to an in-memory store.
+var Buckets Store = NewMemory()
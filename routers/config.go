@@ -1,35 +1,33 @@
 package routers
 
 import (
-	"database/sql"
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/leafee98/class-schedule-to-icalendar-restserver/db"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
-	"github.com/sirupsen/logrus"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/middlewares"
 )
 
 func init() {
-	RegisterRouter("/config-create", "post", configCreate)
-	RegisterRouter("/config-get-by-id", "post", configGetByID)
-	RegisterRouter("/config-get-by-share", "post", configGetByShare)
-	RegisterRouter("/config-modify", "post", configModify)
-	RegisterRouter("/config-remove", "post", configRemove)
-	RegisterRouter("/config-get-list", "post", configGetList)
-
-	RegisterRouter("/config-share-create", "post", configShareCreate)
-	RegisterRouter("/config-share-modify", "post", configShareModify)
-	RegisterRouter("/config-share-revoke", "post", configShareRevoke)
-	RegisterRouter("/config-share-get-list", "post", configShareGetList)
+	RegisterRouter("/config-create", "post", middlewares.ScopeConfigs, (*Handler).configCreate)
+	RegisterRouter("/config-get-by-id", "post", middlewares.ScopeRead, (*Handler).configGetByID)
+	RegisterRouter("/config-get-by-share", "post", middlewares.ScopeRead, (*Handler).configGetByShare)
+	RegisterRouter("/config-modify", "post", middlewares.ScopeConfigs, (*Handler).configModify)
+	RegisterRouter("/config-remove", "post", middlewares.ScopeConfigs, (*Handler).configRemove)
+	RegisterRouter("/config-get-list", "post", middlewares.ScopeRead, (*Handler).configGetList)
+
+	RegisterRouter("/config-share-create", "post", middlewares.ScopeConfigs, (*Handler).configShareCreate)
+	RegisterRouter("/config-share-modify", "post", middlewares.ScopeConfigs, (*Handler).configShareModify)
+	RegisterRouter("/config-share-revoke", "post", middlewares.ScopeConfigs, (*Handler).configShareRevoke)
+	RegisterRouter("/config-share-get-list", "post", middlewares.ScopeRead, (*Handler).configShareGetList)
 }
 
 // ConfigCreate will create a new Config
 //
 // Check if the user is authorized
 // Check the type and format is in range of rule, err: "invalid type or format"
-func configCreate(c *gin.Context) {
+// Check the content with schema, err: ConfigInvalidRes
+func (h *Handler) configCreate(c *gin.Context) {
 	var req dto.ConfigCreateReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -47,17 +45,18 @@ func configCreate(c *gin.Context) {
 		return
 	}
 
+	// check config's content with schema
+	if configContentValidOrAbort(c, req.Content, req.Format, req.Type) != nil {
+		return
+	}
+
 	// insert the created config
-	res, err := db.DB.Exec(
-		"insert into t_config (c_type, c_name, c_content, c_format, c_owner_id, c_remark)"+
-			" values (?, ?, ?, ?, ?, ?)",
-		req.Type, req.Name, req.Content, req.Format, ownerID, req.Remark)
+	configID, err := h.store.Configs.Create(req, ownerID)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, dto.NewResponseBad(err.Error()))
 		return
 	}
 
-	configID, _ := res.LastInsertId()
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.ConfigCreateRes{ID: configID}))
 }
 
@@ -66,7 +65,7 @@ func configCreate(c *gin.Context) {
 // check login status, err msg: "unauthorized action is forbidden"
 // check the deleted status, or no rows got, err msg: "config not exists"
 // check the ownership, err msg: "you are not the owner of the config"
-func configGetByID(c *gin.Context) {
+func (h *Handler) configGetByID(c *gin.Context) {
 	// bind request
 	var req dto.ConfigGetByIDReq
 	if bindOrAbort(c, &req) != nil {
@@ -80,21 +79,9 @@ func configGetByID(c *gin.Context) {
 	}
 
 	// get the config detail
-	var res dto.ConfigGetRes
-	var ownerID int64
-	row := db.DB.QueryRow(
-		"select c_id, c_type, c_name, c_content, c_format, c_remark, c_create_time, c_modify_time, c_owner_id "+
-			"from t_config where c_deleted = false and c_id = ?", req.ID)
-	err := row.Scan(&res.ID, &res.Type, &res.Name, &res.Content, &res.Format,
-		&res.Remark, &res.CreateTime, &res.ModifyTime, &ownerID)
-
+	res, ownerID, err := h.store.Configs.Get(req.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("config not exists"))
-		} else {
-			logrus.Error(err.Error())
-			c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
-		}
+		storeErrorAbort(c, err, "config not exists")
 	} else {
 		// check ownership
 		if userID == ownerID {
@@ -106,7 +93,7 @@ func configGetByID(c *gin.Context) {
 }
 
 // no need to login
-func configGetByShare(c *gin.Context) {
+func (h *Handler) configGetByShare(c *gin.Context) {
 	// bind request
 	var req dto.ConfigGetByShareReq
 	if bindOrAbort(c, &req) != nil {
@@ -114,22 +101,9 @@ func configGetByShare(c *gin.Context) {
 	}
 
 	// get the config detail from share id
-	var res dto.ConfigGetRes
-	var ownerID int64
-	row := db.DB.QueryRow(
-		"select c_id, c_type, c_name, c_content, c_format, c_remark, c_create_time, c_modify_time, c_owner_id "+
-			"from t_config where c_deleted = false and c_id = "+
-			"(select c_config_id from t_config_share where c_id = ?);", req.ID)
-	err := row.Scan(&res.ID, &res.Type, &res.Name, &res.Content, &res.Format,
-		&res.Remark, &res.CreateTime, &res.ModifyTime, &ownerID)
-
+	res, err := h.store.Configs.GetByShare(req.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("config or config share not exists"))
-		} else {
-			logrus.Error(err.Error())
-			c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
-		}
+		storeErrorAbort(c, err, "config or config share not exists")
 	} else {
 		c.JSON(http.StatusOK, dto.NewResponseFine(res))
 	}
@@ -150,8 +124,9 @@ func configGetConfigByShare(c *gin.Context) {
 // check login status, err msg: "unauthorized action is forbidden"
 // check the deleted status, or no rows got, err msg: "config not exists"
 // check the ownership, err msg: "you are not the owner of the config"
+// check the content with schema, err: ConfigInvalidRes
 // update c_modify_time
-func configModify(c *gin.Context) {
+func (h *Handler) configModify(c *gin.Context) {
 	// bind request
 	var req dto.ConfigModifyReq
 	if bindOrAbort(c, &req) != nil {
@@ -164,38 +139,36 @@ func configModify(c *gin.Context) {
 		return
 	}
 
-	// get the config detail
-	var deleted bool
-	var ownerID int64
-	row := db.DB.QueryRow("select c_deleted, c_owner_id from t_config where c_id = ?", req.ID)
-	err := row.Scan(&deleted, &ownerID)
+	// check config's format in range of rule
+	if !checkConfigFormatRange(req.Format) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("invalid format"))
+		return
+	}
 
-	if err != sql.ErrNoRows && err != nil {
-		// unknown error
-		logrus.Error(err.Error())
-		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+	// get the config detail, no such config or deleted is treated as not exists
+	ownerID, configType, err := h.store.Configs.Owner(req.ID)
+	if err != nil {
+		storeErrorAbort(c, err, "config not exists")
+		return
+	}
+
+	// check ownership
+	if userID != ownerID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("you are not the owner of the config"))
 		return
-	} else if err == sql.ErrNoRows || deleted {
-		// no such config or deleted
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("config not exists"))
+	}
+
+	// check config's content with schema of its type
+	if configContentValidOrAbort(c, req.Content, req.Format, configType) != nil {
 		return
-	} else if err == nil {
-		// check ownership
-		if userID != ownerID {
-			c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("you are not the owner of the config"))
-			return
-		}
 	}
 
 	// update the config
-	_, err = db.DB.Exec("update t_config"+
-		" set c_name=?, c_content=?, c_format=?, c_remark=?"+
-		" where c_id = ?",
-		req.Name, req.Content, req.Format, req.Remark, req.ID)
-	if err != nil {
-		logrus.Error(err.Error())
+	if err = h.store.Configs.Modify(req); err != nil {
+		middlewares.Logger(c).Error(err.Error())
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 	} else {
+		h.invalidateConfigCache(c, req.ID)
 		c.JSON(http.StatusOK, dto.NewResponseFine(dto.ConfigModifyRes("ok")))
 	}
 }
@@ -204,7 +177,7 @@ func configModify(c *gin.Context) {
 // check login
 // check exists
 // check ownership
-func configRemove(c *gin.Context) {
+func (h *Handler) configRemove(c *gin.Context) {
 	var req dto.ConfigRemoveReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -216,19 +189,19 @@ func configRemove(c *gin.Context) {
 	}
 
 	// check existence and ownership
-	if configOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.configOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	const sqlCommand string = "update t_config set c_deleted = true where c_id = ?;"
-	res, err := db.DB.Exec(sqlCommand, req.ID)
+	removed, err := h.store.Configs.Remove(req.ID)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
 
-	if affected, _ := res.RowsAffected(); affected > 0 {
+	if removed {
+		h.invalidateConfigCache(c, req.ID)
 		c.JSON(http.StatusOK, dto.NewResponseFine(dto.ConfigRemoveRes("ok")))
 	} else {
 		c.JSON(http.StatusBadGateway, dto.NewResponseBad("bad"))
@@ -237,7 +210,7 @@ func configRemove(c *gin.Context) {
 
 // get config's name, remark, create time and modify time owned by user.
 // result will be from 'offset', 'count' no more than 30
-func configGetList(c *gin.Context) {
+func (h *Handler) configGetList(c *gin.Context) {
 	var req dto.ConfigGetListReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -253,49 +226,18 @@ func configGetList(c *gin.Context) {
 		req.Count = 30
 	}
 
-	switch req.SortBy {
-	case "createTime":
-		req.SortBy = "c_create_time"
-	case "modifyTime":
-		req.SortBy = "c_modify_time"
-	case "name":
-		req.SortBy = "c_name"
-	case "id":
-		req.SortBy = "c_id"
-	default:
-		req.SortBy = "c_id"
-	}
-
-	const sqlCommandPre = "select c_id, c_type, c_name, c_format, c_remark, c_create_time, c_modify_time from t_config" +
-		" where c_owner_id = ? and c_deleted = false order by %s limit ?, ?;"
-	var sqlCommand string = fmt.Sprintf(sqlCommandPre, req.SortBy)
-	rows, err := db.DB.Query(sqlCommand, userID, req.Offset, req.Count)
+	configSummarys, err := h.store.Configs.List(userID, req.SortBy, req.Offset, req.Count)
 	if err != nil {
-		logrus.Error(err)
-		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error))
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
 
-	var configSummarys []dto.ConfigSummary = make([]dto.ConfigSummary, 0)
-	for rows.Next() {
-		var configSummary dto.ConfigSummary
-		rows.Scan(&configSummary.ID,
-			&configSummary.Type,
-			&configSummary.Name,
-			&configSummary.Format,
-			&configSummary.Remark,
-			&configSummary.CreateTime,
-			&configSummary.ModifyTime)
-
-		configSummarys = append(configSummarys, configSummary)
-	}
-	rows.Close()
-
 	c.JSON(http.StatusOK,
 		dto.NewResponseFine(dto.ConfigGetListRes{Count: int64(len(configSummarys)), Configs: configSummarys}))
 }
 
-func configShareCreate(c *gin.Context) {
+func (h *Handler) configShareCreate(c *gin.Context) {
 	var req dto.ConfigShareCreateReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -307,22 +249,20 @@ func configShareCreate(c *gin.Context) {
 	}
 
 	// check existence and ownership
-	if configOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.configOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	const sqlCommand string = "insert into t_config_share (c_config_id, c_remark) values (?, ?);"
-	res, err := db.DB.Exec(sqlCommand, req.ID, req.Remark)
+	inserted, err := h.store.Shares.CreateConfigShare(req.ID, req.Remark)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
-	inserted, _ := res.LastInsertId()
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.ConfigShareCreateRes{ID: inserted}))
 }
 
-func configShareModify(c *gin.Context) {
+func (h *Handler) configShareModify(c *gin.Context) {
 	var req dto.ConfigShareModifyReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -334,21 +274,20 @@ func configShareModify(c *gin.Context) {
 	}
 
 	// check existence and ownership
-	if configShareOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.configShareOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	const sqlCommand = "update t_config_share set c_remark = ? where c_id = ?;"
-	_, err := db.DB.Exec(sqlCommand, req.Remark, req.ID)
-	if err != nil {
-		logrus.Error(err)
+	if err := h.store.Shares.ModifyConfigShare(req.ID, req.Remark); err != nil {
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
+	h.invalidateConfigShareCache(c, req.ID)
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.ConfigShareModifyRes("ok")))
 }
 
-func configShareRevoke(c *gin.Context) {
+func (h *Handler) configShareRevoke(c *gin.Context) {
 	var req dto.ConfigShareRevokeReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -360,20 +299,20 @@ func configShareRevoke(c *gin.Context) {
 	}
 
 	// check existence and ownership
-	if configShareOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.configShareOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	_, err := db.DB.Exec("update t_config_share set c_deleted = true where c_id = ?;", req.ID)
-	if err != nil {
-		logrus.Error(err)
+	if err := h.store.Shares.RevokeConfigShare(req.ID); err != nil {
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
+	h.invalidateConfigShareCache(c, req.ID)
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.ConfigShareRevokeRes("ok")))
 }
 
-func configShareGetList(c *gin.Context) {
+func (h *Handler) configShareGetList(c *gin.Context) {
 	var req dto.ConfigShareGetListReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -385,28 +324,15 @@ func configShareGetList(c *gin.Context) {
 	}
 
 	// check existence and ownership
-	if configOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.configOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	const sqlCommand = "select c_id, c_create_time, c_remark from t_config_share " +
-		"where c_deleted = false and c_config_id = ?;"
-	rows, err := db.DB.Query(sqlCommand, req.ID)
-	defer rows.Close()
+	shareDetails, err := h.store.Shares.ListConfigShares(req.ID)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
-	var shareDetails []dto.ConfigShareDetail = make([]dto.ConfigShareDetail, 0)
-	for rows.Next() {
-		var shareDetail dto.ConfigShareDetail
-		if err := rows.Scan(&shareDetail.ID, &shareDetail.CreateTime, &shareDetail.Remark); err != nil {
-			logrus.Error(err)
-			c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
-			return
-		}
-		shareDetails = append(shareDetails, shareDetail)
-	}
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.ConfigShareGetListRes{Shares: shareDetails}))
 }
@@ -1,150 +1,292 @@
 package routers
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/leafee98/class-schedule-to-icalendar-restserver/db"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/cache"
+	confcontent "github.com/leafee98/class-schedule-to-icalendar-restserver/content"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/metrics"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/middlewares"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/rpc"
-	"github.com/sirupsen/logrus"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
 )
 
 func init() {
-	RegisterRouter("/generate-by-plan-token", "get", generateByPlanToken)
-	RegisterRouter("/generate-by-plan-share", "get", generateByPlanShare)
+	RegisterRouter("/generate-by-plan-token", "get", scopeNone, (*Handler).generateByPlanToken)
+	RegisterRouter("/generate-by-plan-share", "get", scopeNone, (*Handler).generateByPlanShare)
+	RegisterRouter("/plan-generate-preview", "post", middlewares.ScopeRead, (*Handler).planGeneratePreview)
 }
 
 // require the token in get request
 // check the existence of token
 // check the existence of plan
 // validate config share
-func generateByPlanToken(c *gin.Context) {
+func (h *Handler) generateByPlanToken(c *gin.Context) {
 	var req dto.GenerateByPlanTokenReq
 	if bindOrAbort(c, &req) != nil {
 		return
 	}
 
-	const sqlGetPlanId string = `
-		select c_id from t_plan where c_deleted = false and c_id = (
-			select c_plan_id from t_plan_token where c_token = ?);`
-
-	var planID int64
-	row := db.DB.QueryRow(sqlGetPlanId, req.Token)
-	err := row.Scan(&planID)
-	if err == sql.ErrNoRows {
+	planID, err := h.store.Plans.TokenPlan(req.Token)
+	if err == store.ErrNotFound {
 		// invalid token or deleted plan
 		c.AbortWithStatus(http.StatusBadRequest)
 		return
 	} else if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	generateFromPlanId(c, planID)
+	h.generateFromPlanId(c, planID)
 }
 
-func generateByPlanShare(c *gin.Context) {
-	const sqlGetPlanId string = `select c_plan_id from t_plan_share where c_deleted = false and c_id = ?;`
-
+func (h *Handler) generateByPlanShare(c *gin.Context) {
 	var req dto.GenerateByPlanShareReq
 	if bindOrAbort(c, &req) != nil {
 		return
 	}
 
-	var planID int64
-	row := db.DB.QueryRow(sqlGetPlanId, req.ShareID)
-	err := row.Scan(&planID)
-	if err == sql.ErrNoRows {
+	planID, err := h.store.Shares.PlanSharePlan(req.ShareID)
+	if err == store.ErrNotFound {
 		// invalid token or deleted plan
 		c.AbortWithStatus(http.StatusBadRequest)
 		return
 	} else if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	generateFromPlanId(c, planID)
+	h.generateFromPlanId(c, planID)
+}
+
+// only owner could preview the plan
+//
+// check login status
+// check plan existence and ownership
+// replace or add the unsaved configs, check their format, type and content
+// respond both the generate result and the envelope sent to rpc server
+func (h *Handler) planGeneratePreview(c *gin.Context) {
+	var req dto.PlanGeneratePreviewReq
+	if bindOrAbort(c, &req) != nil {
+		return
+	}
+
+	var userID int64
+	if getUserIDOrAbort(c, &userID) != nil {
+		return
+	}
+
+	if h.planOwnershipOrAbort(c, req.ID, userID) != nil {
+		return
+	}
+
+	configs, err := h.store.Plans.Configs(req.ID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	}
+
+	// new configs will be placed after all plan's own configs
+	var nextID int64 = 1
+	for _, conf := range configs {
+		if conf.ID >= nextID {
+			nextID = conf.ID + 1
+		}
+	}
+
+	for _, unsaved := range req.Configs {
+		if !checkConfigFormatRange(unsaved.Format) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("invalid format"))
+			return
+		}
+
+		if unsaved.ID == 0 {
+			if !checkConfigTypeRange(unsaved.Type) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("invalid type"))
+				return
+			}
+			if configContentValidOrAbort(c, unsaved.Content, unsaved.Format, unsaved.Type) != nil {
+				return
+			}
+			configs = append(configs, confcontent.Config{
+				ID: nextID, Type: unsaved.Type, Format: unsaved.Format, Content: unsaved.Content})
+			nextID++
+			continue
+		}
+
+		// replace the content of config in plan, the type is not changable
+		var replaced bool = false
+		for i := range configs {
+			if configs[i].ID != unsaved.ID {
+				continue
+			}
+			if configContentValidOrAbort(c, unsaved.Content, unsaved.Format, configs[i].Type) != nil {
+				return
+			}
+			configs[i].Content = unsaved.Content
+			configs[i].Format = unsaved.Format
+			replaced = true
+		}
+		if !replaced {
+			c.AbortWithStatusJSON(http.StatusBadRequest,
+				dto.NewResponseBad(fmt.Sprintf("config %d is not in the plan", unsaved.ID)))
+			return
+		}
+	}
+
+	envelope, err := confcontent.BuildEnvelope(configs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(err.Error()))
+		return
+	}
+
+	generateRes, err := rpc.JSONGenerate(c.Request.Context(), string(envelope))
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		if rpcUnavailableAbort(c, err) {
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewResponseFine(
+		dto.PlanGeneratePreviewRes{Content: generateRes, Envelope: string(envelope)}))
 }
 
 //////////////////////////////////////////
 //////// Generation Utility //////////////
 //////////////////////////////////////////
 
-func generateFromPlanId(c *gin.Context, planID int64) {
-	const sqlGetConfig string = `
-		select c_content, c_type, c_format
-		from t_config
-		where c_deleted = false 
-			and c_id in (
-				select c_config_id
-				from t_plan_config_relation
-				where c_plan_id = ?
-			)
-		union all
-		select c_content, c_type, c_format
-		from t_config
-		where c_deleted = false
-			and c_id in (
-				select c_config_id
-				from t_config_share
-				where c_deleted = false
-					and c_id in (
-						select c_config_share_id
-						from t_plan_config_share_relation
-						where c_plan_id = ?
-					)
-			);`
-
-	rows, err := db.DB.Query(sqlGetConfig, planID, planID)
-	defer rows.Close()
+func (h *Handler) generateFromPlanId(c *gin.Context, planID int64) {
+	configs, err := h.store.Plans.Configs(planID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lastModified, err := h.planLastModified(planID, configs)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	const configJSONFormat string = `{ "global": %s, "lessons": [ %s ] }`
-	var configGlobal string
-	var configLessons []string = make([]string, 0)
-	for rows.Next() {
-		var content string
-		var globalOrLesson, format int8
-		err := rows.Scan(&content, &globalOrLesson, &format)
+	envelope, err := confcontent.BuildEnvelope(configs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hash := cache.Hash(envelope)
+	etag := `"` + hash + `"`
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	if notModified(c, etag, lastModified) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+
+	generateRes, ok := cache.Get(planID, hash)
+	if !ok {
+		generateRes, err = rpc.JSONGenerate(c.Request.Context(), string(envelope))
 		if err != nil {
-			logrus.Error(err)
+			middlewares.Logger(c).Error(err)
+			if rpcUnavailableAbort(c, err) {
+				return
+			}
 			c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
 			return
 		}
+		cache.Set(planID, hash, generateRes)
+	}
+	metrics.PlanGenerations.Inc()
+	// a failed count should not fail the generation
+	if err = h.store.Plans.CountGeneration(planID); err != nil {
+		middlewares.Logger(c).Error(err)
+	}
 
-		if globalOrLesson == 1 {
-			configGlobal = content
-		} else if globalOrLesson == 2 {
-			configLessons = append(configLessons, content)
-		}
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="plan-%d.ics"`, planID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(generateRes))
+}
+
+// abort with 503 and Retry-After if the rpc server is considered down by the circuit breaker,
+// return false if err is caused by others
+func rpcUnavailableAbort(c *gin.Context, err error) bool {
+	var open *rpc.CircuitOpenError
+	if !errors.As(err, &open) {
+		return false
 	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(open.RetryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, dto.NewResponseBad(err.Error()))
+	return true
+}
 
-	var configLessonsStr strings.Builder
-	for i, s := range configLessons {
-		if i != 0 {
-			configLessonsStr.WriteString(",")
+// return true if the client's copy is still fresh.
+// If-None-Match take precedence over If-Modified-Since, see rfc7232 section 6
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == "*" || t == etag {
+				return true
+			}
 		}
-		configLessonsStr.WriteString(s)
+		return false
 	}
 
-	var configJSONRes string = fmt.Sprintf(configJSONFormat, configGlobal, configLessonsStr.String())
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		// the precision of http date is second
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
 
-	generateRes, err := rpc.JSONGenerate(configJSONRes)
+// the latest modify time of the plan and its configs,
+// removing a config or config share from the plan in any way update the plan's one
+func (h *Handler) planLastModified(planID int64, configs []confcontent.Config) (time.Time, error) {
+	lastModified, err := h.store.Plans.ModifyTime(planID)
 	if err != nil {
-		logrus.Error(err)
-		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return lastModified, err
+	}
+
+	for _, conf := range configs {
+		if conf.ModifyTime.After(lastModified) {
+			lastModified = conf.ModifyTime
+		}
+	}
+	return lastModified, nil
+}
+
+// drop the cached generate result of plans which use the config directly or by share
+func (h *Handler) invalidateConfigCache(c *gin.Context, configID int64) {
+	planIDs, err := h.store.Plans.IDsByConfig(configID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
 		return
 	}
+	cache.Invalidate(planIDs...)
+}
 
-	c.String(http.StatusOK, generateRes)
+// drop the cached generate result of plans which use the config share
+func (h *Handler) invalidateConfigShareCache(c *gin.Context, configShareID int64) {
+	planIDs, err := h.store.Plans.IDsByConfigShare(configShareID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		return
+	}
+	cache.Invalidate(planIDs...)
 }
@@ -1,43 +1,41 @@
 package routers
 
 import (
-	"database/sql"
-	"errors"
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/leafee98/class-schedule-to-icalendar-restserver/db"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/cache"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/middlewares"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/utils"
-	"github.com/sirupsen/logrus"
 )
 
 func init() {
-	RegisterRouter("plan-create", "post", planCreate)
-	RegisterRouter("plan-add-config", "post", planAddConfig)
-	RegisterRouter("plan-remove-config", "post", planRemoveConfig)
-	RegisterRouter("plan-add-share", "post", planAddShare)
-	RegisterRouter("plan-remove-share", "post", planRemoveShare)
-	RegisterRouter("plan-get-by-id", "post", planGetById)
-	RegisterRouter("plan-get-by-share", "post", planGetByShare)
-	RegisterRouter("plan-remove", "post", planRemove)
-	RegisterRouter("plan-modify", "post", planModify)
-	RegisterRouter("plan-get-list", "post", planGetList)
-
-	RegisterRouter("plan-create-token", "post", planCreateToken)
-	RegisterRouter("plan-revoke-token", "post", planRevokeToken)
-	RegisterRouter("plan-get-token-list", "post", planGetTokenList)
-
-	RegisterRouter("/plan-share-create", "post", planShareCreate)
-	RegisterRouter("/plan-share-modify", "post", planShareModify)
-	RegisterRouter("/plan-share-revoke", "post", planShareRevoke)
-	RegisterRouter("/plan-share-get-list", "post", planShareGetList)
+	RegisterRouter("plan-create", "post", middlewares.ScopePlans, (*Handler).planCreate)
+	RegisterRouter("plan-add-config", "post", middlewares.ScopePlans, (*Handler).planAddConfig)
+	RegisterRouter("plan-remove-config", "post", middlewares.ScopePlans, (*Handler).planRemoveConfig)
+	RegisterRouter("plan-add-share", "post", middlewares.ScopePlans, (*Handler).planAddShare)
+	RegisterRouter("plan-remove-share", "post", middlewares.ScopePlans, (*Handler).planRemoveShare)
+	RegisterRouter("plan-get-by-id", "post", middlewares.ScopeRead, (*Handler).planGetById)
+	RegisterRouter("plan-get-by-share", "post", middlewares.ScopeRead, (*Handler).planGetByShare)
+	RegisterRouter("plan-remove", "post", middlewares.ScopePlans, (*Handler).planRemove)
+	RegisterRouter("plan-modify", "post", middlewares.ScopePlans, (*Handler).planModify)
+	RegisterRouter("plan-get-list", "post", middlewares.ScopeRead, (*Handler).planGetList)
+
+	RegisterRouter("plan-create-token", "post", middlewares.ScopePlans, (*Handler).planCreateToken)
+	RegisterRouter("plan-revoke-token", "post", middlewares.ScopePlans, (*Handler).planRevokeToken)
+	RegisterRouter("plan-get-token-list", "post", middlewares.ScopePlans, (*Handler).planGetTokenList)
+
+	RegisterRouter("/plan-share-create", "post", middlewares.ScopePlans, (*Handler).planShareCreate)
+	RegisterRouter("/plan-share-modify", "post", middlewares.ScopePlans, (*Handler).planShareModify)
+	RegisterRouter("/plan-share-revoke", "post", middlewares.ScopePlans, (*Handler).planShareRevoke)
+	RegisterRouter("/plan-share-get-list", "post", middlewares.ScopeRead, (*Handler).planShareGetList)
 }
 
 // create a plan
 // only need a Name and Remark, return ID
-func planCreate(c *gin.Context) {
+func (h *Handler) planCreate(c *gin.Context) {
 	// bind parameter
 	var req dto.PlanCreateReq
 	if bindOrAbort(c, &req) != nil {
@@ -50,14 +48,12 @@ func planCreate(c *gin.Context) {
 		return
 	}
 
-	res, err := db.DB.Exec("insert into t_plan (c_name, c_owner_id, c_remark) values (?, ?, ?);",
-		req.Name, userID, req.Remark)
+	planID, err := h.store.Plans.Create(req, userID)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
 
-	planID, _ := res.LastInsertId()
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanCreateRes{ID: planID}))
 }
 
@@ -65,8 +61,7 @@ func planCreate(c *gin.Context) {
 // check if plan & config exist
 // check if the relation already exist
 // check ownership
-// todo: transaction
-func planAddConfig(c *gin.Context) {
+func (h *Handler) planAddConfig(c *gin.Context) {
 	// bind request
 	var req dto.PlanAddConfigReq
 	if bindOrAbort(c, &req) != nil {
@@ -80,32 +75,28 @@ func planAddConfig(c *gin.Context) {
 	}
 
 	// check ownership
-	if planOwnershipOrAbort(c, req.PlanID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.PlanID, userID) != nil {
 		return
 	}
-	if configOwnershipOrAbort(c, req.ConfigID, userID) != nil {
+	if h.configOwnershipOrAbort(c, req.ConfigID, userID) != nil {
 		return
 	}
 
-	// check relation exist
-	err3 := relationExist(req.PlanID, req.ConfigID)
-	if err3 == nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("this config already added to the plan"))
+	// check relation exist and create relation
+	err := h.store.Plans.AddConfig(req.PlanID, req.ConfigID)
+	if err == store.ErrDuplicate {
+		c.AbortWithStatusJSON(http.StatusConflict, dto.NewResponseBad("this config already added to the plan"))
 		return
-	}
-
-	// create relation
-	_, err := db.DB.Exec("insert into t_plan_config_relation (c_plan_id, c_config_id) values (?, ?)",
-		req.PlanID, req.ConfigID)
-	if err != nil {
-		logrus.Error(err)
+	} else if err != nil {
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
+	cache.Invalidate(req.PlanID)
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanAddConfigRes("ok")))
 }
 
-func planRemoveConfig(c *gin.Context) {
+func (h *Handler) planRemoveConfig(c *gin.Context) {
 	// bind request
 	var req dto.PlanRemoveConfigReq
 	if bindOrAbort(c, &req) != nil {
@@ -119,30 +110,34 @@ func planRemoveConfig(c *gin.Context) {
 	}
 
 	// check ownership
-	if planOwnershipOrAbort(c, req.PlanID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.PlanID, userID) != nil {
 		return
 	}
-	if configOwnershipOrAbort(c, req.ConfigID, userID) != nil {
+	if h.configOwnershipOrAbort(c, req.ConfigID, userID) != nil {
 		return
 	}
 
 	// check relation exist
-	err3 := relationExist(req.PlanID, req.ConfigID)
-	if err3 != nil {
+	exist, err := h.store.Plans.ConfigExist(req.PlanID, req.ConfigID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	} else if !exist {
 		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("this config haven't been added to the plan"))
 		return
 	}
 
 	// remove the relation
-	const sqlCommand string = `delete from t_plan_config_relation where c_plan_id = ? and c_config_id = ?;`
-	res, err := db.DB.Exec(sqlCommand, req.PlanID, req.ConfigID)
+	removed, err := h.store.Plans.RemoveConfig(req.PlanID, req.ConfigID)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
 
-	if affected, _ := res.RowsAffected(); affected > 0 {
+	if removed {
+		cache.Invalidate(req.PlanID)
 		c.JSON(http.StatusOK, dto.NewResponseFine("ok"))
 	} else {
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad("no relation deleted"))
@@ -153,7 +148,7 @@ func planRemoveConfig(c *gin.Context) {
 // check if plan & config share exist
 // check if the relation already exist
 // check plan ownership
-func planAddShare(c *gin.Context) {
+func (h *Handler) planAddShare(c *gin.Context) {
 	// bind request
 	var req dto.PlanAddShareReq
 	if bindOrAbort(c, &req) != nil {
@@ -167,33 +162,29 @@ func planAddShare(c *gin.Context) {
 	}
 
 	// check plan ownership
-	if planOwnershipOrAbort(c, req.PlanID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.PlanID, userID) != nil {
 		return
 	}
 	// check config share existence
-	if configShareExistOrAbort(c, req.ConfigShareID) != nil {
+	if h.configShareExistOrAbort(c, req.ConfigShareID) != nil {
 		return
 	}
 
-	// check relation exist
-	err3 := relationShareExist(req.PlanID, req.ConfigShareID)
-	if err3 == nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("this config share already added to the plan"))
+	// check relation exist and create relation
+	err := h.store.Plans.AddConfigShare(req.PlanID, req.ConfigShareID)
+	if err == store.ErrDuplicate {
+		c.AbortWithStatusJSON(http.StatusConflict, dto.NewResponseBad("this config share already added to the plan"))
 		return
-	}
-
-	// create relation
-	_, err := db.DB.Exec("insert into t_plan_config_share_relation (c_plan_id, c_config_share_id) values (?, ?);",
-		req.PlanID, req.ConfigShareID)
-	if err != nil {
-		logrus.Error(err)
+	} else if err != nil {
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
+	cache.Invalidate(req.PlanID)
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanAddConfigRes("ok")))
 }
 
-func planRemoveShare(c *gin.Context) {
+func (h *Handler) planRemoveShare(c *gin.Context) {
 	// bind request
 	var req dto.PlanRemoveShareReq
 	if bindOrAbort(c, &req) != nil {
@@ -207,27 +198,31 @@ func planRemoveShare(c *gin.Context) {
 	}
 
 	// check plan ownership
-	if planOwnershipOrAbort(c, req.PlanID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.PlanID, userID) != nil {
 		return
 	}
 
 	// check relation of share exist
-	err3 := relationShareExist(req.PlanID, req.ConfigShareID)
-	if err3 != nil {
+	exist, err := h.store.Plans.ConfigShareExist(req.PlanID, req.ConfigShareID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	} else if !exist {
 		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("this config haven't been added to the plan"))
 		return
 	}
 
 	// remove the relation
-	const sqlCommand string = `delete from t_plan_config_share_relation where c_plan_id = ? and c_config_share_id = ?;`
-	res, err := db.DB.Exec(sqlCommand, req.PlanID, req.ConfigShareID)
+	removed, err := h.store.Plans.RemoveConfigShare(req.PlanID, req.ConfigShareID)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
 
-	if affected, _ := res.RowsAffected(); affected > 0 {
+	if removed {
+		cache.Invalidate(req.PlanID)
 		c.JSON(http.StatusOK, dto.NewResponseFine("ok"))
 	} else {
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad("no relation of share deleted"))
@@ -236,7 +231,7 @@ func planRemoveShare(c *gin.Context) {
 
 // check login status
 // check plan existence and ownership
-func planGetById(c *gin.Context) {
+func (h *Handler) planGetById(c *gin.Context) {
 	var req dto.PlanGetByIdReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -247,43 +242,38 @@ func planGetById(c *gin.Context) {
 		return
 	}
 
-	if planOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	var res dto.PlanGetRes
-	if err := planGetRes(&res, req.ID); err != nil {
-		logrus.Error(err)
-		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+	if res, err := h.store.Plans.Get(req.ID); err != nil {
+		storeErrorAbort(c, err, "plan not exist")
 	} else {
 		c.JSON(http.StatusOK, dto.NewResponseFine(res))
 	}
 }
 
-func planGetByShare(c *gin.Context) {
+func (h *Handler) planGetByShare(c *gin.Context) {
 	var req dto.PlanGetByShareReq
 	if bindOrAbort(c, &req) != nil {
 		return
 	}
 
 	// get plan id
-	var planID int64
-	row := db.DB.QueryRow("select c_plan_id from t_plan_share where c_id = ?;", req.ID)
-	if err := row.Scan(&planID); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("plan share not exist"))
+	planID, err := h.store.Shares.PlanSharePlan(req.ID)
+	if err != nil {
+		storeErrorAbort(c, err, "plan share not exist")
 		return
 	}
 
-	var res dto.PlanGetRes
-	if err := planGetRes(&res, planID); err != nil {
-		logrus.Error(err)
-		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+	if res, err := h.store.Plans.Get(planID); err != nil {
+		storeErrorAbort(c, err, "plan not exist")
 	} else {
 		c.JSON(http.StatusOK, dto.NewResponseFine(res))
 	}
 }
 
-func planRemove(c *gin.Context) {
+func (h *Handler) planRemove(c *gin.Context) {
 	var req dto.PlanRemoveReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -294,25 +284,25 @@ func planRemove(c *gin.Context) {
 		return
 	}
 
-	if planOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	const sqlCommand string = "update t_plan set c_deleted = true where c_id = ?;"
-	res, err := db.DB.Exec(sqlCommand, req.ID)
+	removed, err := h.store.Plans.Remove(req.ID)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
-	if affected, _ := res.RowsAffected(); affected > 0 {
+	if removed {
+		cache.Invalidate(req.ID)
 		c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanRemoveRes("ok")))
 	} else {
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad("deleted nothing"))
 	}
 }
 
-func planModify(c *gin.Context) {
+func (h *Handler) planModify(c *gin.Context) {
 	var req dto.PlanModifyReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -323,21 +313,20 @@ func planModify(c *gin.Context) {
 		return
 	}
 
-	if planOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	const sqlCommand = "update t_plan set c_name = ?, c_remark = ?  where c_id = ?;"
-	_, err := db.DB.Exec(sqlCommand, req.Name, req.Remark, req.ID)
-	if err != nil {
-		logrus.Error(err)
+	if err := h.store.Plans.Modify(req); err != nil {
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
+	cache.Invalidate(req.ID)
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanRemoveRes("ok")))
 }
 
-func planGetList(c *gin.Context) {
+func (h *Handler) planGetList(c *gin.Context) {
 	var req dto.PlanGetListReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -353,49 +342,23 @@ func planGetList(c *gin.Context) {
 		req.Count = 30
 	}
 
-	switch req.SortBy {
-	case "createTime":
-		req.SortBy = "c_create_time"
-	case "modifyTime":
-		req.SortBy = "c_modify_time"
-	case "name":
-		req.SortBy = "c_name"
-	case "id":
-		req.SortBy = "c_id"
-	default:
-		req.SortBy = "c_id"
-	}
-
-	const sqlCommandPre = "select c_id, c_name, c_remark, c_create_time, c_modify_time" +
-		" from t_plan where c_owner_id = ? and c_deleted = false order by %s limit ?, ?;"
-	var sqlCommand string = fmt.Sprintf(sqlCommandPre, req.SortBy)
-	rows, err := db.DB.Query(sqlCommand, userID, req.Offset, req.Count)
+	planSummarys, err := h.store.Plans.List(userID, req.SortBy, req.Offset, req.Count)
 	if err != nil {
-		logrus.Error(err)
-		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error))
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
 
-	var planSummarys []dto.PlanSummary = make([]dto.PlanSummary, 0)
-	for rows.Next() {
-		var planSummary dto.PlanSummary
-		rows.Scan(&planSummary.ID,
-			&planSummary.Name,
-			&planSummary.Remark,
-			&planSummary.CreateTime,
-			&planSummary.ModifyTime)
-
-		planSummarys = append(planSummarys, planSummary)
-	}
-	rows.Close()
-
 	c.JSON(http.StatusOK,
 		dto.NewResponseFine(dto.PlanGetListRes{Count: int64(len(planSummarys)), Plans: planSummarys}))
 }
 
+// the max number of tokens of the same plan
+const maxPlanTokens = 30
+
 // check delete status
 // check ownership
-func planCreateToken(c *gin.Context) {
+func (h *Handler) planCreateToken(c *gin.Context) {
 	var req dto.PlanCreateTokenReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -407,28 +370,19 @@ func planCreateToken(c *gin.Context) {
 	}
 
 	// existence and ownership
-	if planOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
 	// cannot create token of plan more than 30
-	var tokenCount int64
-	row := db.DB.QueryRow("select count(*) from t_plan_token where c_plan_id = ?;", req.ID)
-	err := row.Scan(&tokenCount)
-	if err != nil {
-		logrus.Error(err)
-		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
-	}
-	if tokenCount >= 30 {
+	token := utils.GenerateToken()
+	err := h.store.Plans.CreateToken(req.ID, token, maxPlanTokens)
+	if err == store.ErrLimitExceeded {
 		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(
 			"number of tokens of the same plan cannot be more than 30, revoke some tokens before create more."))
 		return
-	}
-
-	token := utils.GenerateToken()
-	_, err = db.DB.Exec("insert into t_plan_token (c_plan_id, c_token) values (?, ?)", req.ID, token)
-	if err != nil {
-		logrus.Error(err)
+	} else if err != nil {
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
@@ -438,7 +392,7 @@ func planCreateToken(c *gin.Context) {
 // check login status
 // check token existence
 // check plan existence and ownership
-func planRevokeToken(c *gin.Context) {
+func (h *Handler) planRevokeToken(c *gin.Context) {
 	var req dto.PlanRevokeTokenReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -450,30 +404,24 @@ func planRevokeToken(c *gin.Context) {
 	}
 
 	// get planID from token
-	var planID int64
-	row := db.DB.QueryRow("select c_plan_id from t_plan_token where c_token = ?;", req.Token)
-	err := row.Scan(&planID)
-	if err == sql.ErrNoRows {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("no such token"))
-		return
-	} else if err != nil {
-		logrus.Error(err)
-		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+	planID, err := h.store.Plans.TokenPlan(req.Token)
+	if err != nil {
+		storeErrorAbort(c, err, "no such token")
 		return
 	}
 
-	if planOwnershipOrAbort(c, planID, userID) != nil {
+	if h.planOwnershipOrAbort(c, planID, userID) != nil {
 		return
 	}
 
 	// delete this token
-	res, err := db.DB.Exec("delete from t_plan_token where c_token = ?;", req.Token)
+	revoked, err := h.store.Plans.RevokeToken(req.Token)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
-	if affected, _ := res.RowsAffected(); affected > 0 {
+	if revoked {
 		c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanRevokeTokenRes("ok")))
 	} else {
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(dto.PlanRevokeTokenRes("deleted nothing")))
@@ -482,7 +430,7 @@ func planRevokeToken(c *gin.Context) {
 
 // check login status
 // check plan existence and ownership
-func planGetTokenList(c *gin.Context) {
+func (h *Handler) planGetTokenList(c *gin.Context) {
 	var req dto.PlanGetTokenListReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -493,27 +441,20 @@ func planGetTokenList(c *gin.Context) {
 		return
 	}
 
-	if planOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	rows, err := db.DB.Query("select c_token, c_create_time from t_plan_token where c_plan_id = ?;", req.ID)
+	tokens, err := h.store.Plans.ListTokens(req.ID)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
-	var tokens []dto.PlanTokenDetail = make([]dto.PlanTokenDetail, 0)
-	var token dto.PlanTokenDetail
-	for rows.Next() {
-		rows.Scan(&token.Token, &token.CreateTime)
-		tokens = append(tokens, token)
-	}
-	rows.Close()
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanGetTokenListRes{Count: int64(len(tokens)), Tokens: tokens}))
 }
 
-func planShareCreate(c *gin.Context) {
+func (h *Handler) planShareCreate(c *gin.Context) {
 	var req dto.PlanShareCreateReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -525,22 +466,20 @@ func planShareCreate(c *gin.Context) {
 	}
 
 	// check existence and ownership
-	if planOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	const sqlCommand string = "insert into t_plan_share (c_plan_id, c_remark) values (?, ?);"
-	res, err := db.DB.Exec(sqlCommand, req.ID, req.Remark)
+	inserted, err := h.store.Shares.CreatePlanShare(req.ID, req.Remark)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
-	inserted, _ := res.LastInsertId()
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanShareCreateRes{ID: inserted}))
 }
 
-func planShareModify(c *gin.Context) {
+func (h *Handler) planShareModify(c *gin.Context) {
 	var req dto.PlanShareModifyReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -552,21 +491,19 @@ func planShareModify(c *gin.Context) {
 	}
 
 	// check existence and ownership
-	if planShareOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.planShareOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	const sqlCommand = "update t_plan_share set c_remark = ? where c_id = ?;"
-	_, err := db.DB.Exec(sqlCommand, req.Remark, req.ID)
-	if err != nil {
-		logrus.Error(err)
+	if err := h.store.Shares.ModifyPlanShare(req.ID, req.Remark); err != nil {
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanShareModifyRes("ok")))
 }
 
-func planShareRevoke(c *gin.Context) {
+func (h *Handler) planShareRevoke(c *gin.Context) {
 	var req dto.PlanShareRevokeReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -578,20 +515,19 @@ func planShareRevoke(c *gin.Context) {
 	}
 
 	// check existence and ownership
-	if planShareOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.planShareOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	_, err := db.DB.Exec("update t_plan_share set c_deleted = true where c_id = ?;", req.ID)
-	if err != nil {
-		logrus.Error(err)
+	if err := h.store.Shares.RevokePlanShare(req.ID); err != nil {
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanShareRevokeRes("ok")))
 }
 
-func planShareGetList(c *gin.Context) {
+func (h *Handler) planShareGetList(c *gin.Context) {
 	var req dto.PlanShareGetListReq
 	if bindOrAbort(c, &req) != nil {
 		return
@@ -603,99 +539,15 @@ func planShareGetList(c *gin.Context) {
 	}
 
 	// check existence and ownership
-	if planOwnershipOrAbort(c, req.ID, userID) != nil {
+	if h.planOwnershipOrAbort(c, req.ID, userID) != nil {
 		return
 	}
 
-	const sqlCommand = "select c_id, c_create_time, c_remark from t_plan_share " +
-		"where c_deleted = false and c_plan_id = ?;"
-	rows, err := db.DB.Query(sqlCommand, req.ID)
-	defer rows.Close()
+	shareDetails, err := h.store.Shares.ListPlanShares(req.ID)
 	if err != nil {
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 		return
 	}
-	var shareDetails []dto.PlanShareDetail = make([]dto.PlanShareDetail, 0)
-	for rows.Next() {
-		var shareDetail dto.PlanShareDetail
-		if err := rows.Scan(&shareDetail.ID, &shareDetail.CreateTime, &shareDetail.Remark); err != nil {
-			logrus.Error(err)
-			c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
-			return
-		}
-		shareDetails = append(shareDetails, shareDetail)
-	}
 	c.JSON(http.StatusOK, dto.NewResponseFine(dto.PlanShareGetListRes{Shares: shareDetails}))
 }
-
-////////////////////////////////////////////////
-/////////////////// Utilities //////////////////
-////////////////////////////////////////////////
-
-func planGetRes(plan *dto.PlanGetRes, planID int64) error {
-	plan.Configs = make([]dto.ConfigDetail, 0)
-	plan.Shares = make([]dto.ConfigDetail, 0)
-
-	const sqlCommandGetPlan string = "select c_id, c_name, c_remark, c_create_time, c_modify_time " +
-		"from t_plan where c_deleted = false and c_id = ?;"
-	row := db.DB.QueryRow(sqlCommandGetPlan, planID)
-	if err := row.Scan(&plan.ID, &plan.Name, &plan.Remark, &plan.CreateTime, &plan.ModifyTime); err != nil {
-		if err == sql.ErrNoRows {
-			return errors.New("plan not exist")
-		} else {
-			return err
-		}
-	}
-
-	const sqlCommandGetConfigs = "select " +
-		"c_id, c_type, c_name, c_content, c_format, c_remark, c_create_time, c_modify_time " +
-		"from t_config where c_deleted = false and c_id in " +
-		"(select c_config_id from t_plan_config_relation where c_plan_id = ?);"
-	rows, err := db.DB.Query(sqlCommandGetConfigs, planID)
-	defer rows.Close()
-	if err != nil {
-		return err
-	}
-
-	for rows.Next() {
-		var co dto.ConfigDetail
-		err = rows.Scan(&co.ID, &co.Type, &co.Name, &co.Content, &co.Format, &co.Remark, &co.CreateTime, &co.ModifyTime)
-		if err != nil {
-			return err
-		}
-		plan.Configs = append(plan.Configs, co)
-	}
-
-	const sqlCommandGetShares = "" +
-		"select s.c_id, c.c_type, c.c_name, c.c_content, c.c_format, c.c_remark, c.c_create_time, c.c_modify_time " +
-		"from " +
-		"	t_config as c " +
-		"	join t_config_share as s " +
-		"	on c.c_id = s.c_config_id " +
-		"where " +
-		"	c.c_deleted = false " +
-		"	and s.c_deleted = false " +
-		"	and	s.c_id in ( " +
-		"		select c_config_share_id " +
-		"		from t_plan_config_share_relation " +
-		"		where c_plan_id = ? " +
-		"	);"
-	rowsShare, err := db.DB.Query(sqlCommandGetShares, planID)
-	defer rowsShare.Close()
-	if err != nil {
-		return err
-	}
-
-	for rowsShare.Next() {
-		var cs dto.ConfigDetail
-		err = rowsShare.Scan(&cs.ID, &cs.Type, &cs.Name, &cs.Content, &cs.Format, &cs.Remark, &cs.CreateTime,
-			&cs.ModifyTime)
-		if err != nil {
-			return err
-		}
-		plan.Shares = append(plan.Shares, cs)
-	}
-
-	return nil
-}
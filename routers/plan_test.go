@@ -0,0 +1,121 @@
+package routers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/middlewares"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+)
+
+// stubPlans is a PlanStore of plans owned by user 1, failing the calls with err
+type stubPlans struct {
+	store.PlanStore
+	err   error
+	added [][2]int64
+}
+
+func (s *stubPlans) Owner(planID int64) (int64, error) {
+	if planID > 10 {
+		return 0, store.ErrNotFound
+	}
+	return 1, nil
+}
+
+func (s *stubPlans) AddConfig(planID int64, configID int64) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.added = append(s.added, [2]int64{planID, configID})
+	return nil
+}
+
+func (s *stubPlans) CreateToken(planID int64, token string, max int64) error {
+	return s.err
+}
+
+// stubConfigs is a ConfigStore of global configs, those with ID less than 10 are owned by user 1
+type stubConfigs struct {
+	store.ConfigStore
+}
+
+func (stubConfigs) Owner(configID int64) (int64, int8, error) {
+	if configID < 10 {
+		return 1, 1, nil
+	}
+	return 2, 1, nil
+}
+
+// serve the handler with req posted by user, and return the status code and the message of response
+func serve(t *testing.T, h *Handler, f func(*Handler, *gin.Context), userID int64, req gin.H) (int, string) {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(middlewares.Key.UserID, userID)
+	f(h, c)
+
+	var res struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err = json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+	var msg string
+	json.Unmarshal(res.Data, &msg)
+	return w.Code, msg
+}
+
+func TestPlanAddConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name    string
+		userID  int64
+		req     gin.H
+		err     error
+		want    int
+		wantMsg string
+	}{
+		{"added", 1, gin.H{"planId": 1, "configId": 2}, nil, http.StatusOK, "ok"},
+		{"missing config", 1, gin.H{"planId": 1}, nil, http.StatusBadRequest, "invalid request parameters"},
+		{"plan not exist", 1, gin.H{"planId": 11, "configId": 2}, nil, http.StatusBadRequest, "the plan not exist"},
+		{"plan of others", 2, gin.H{"planId": 1, "configId": 12}, nil, http.StatusBadRequest, "you are not the owner of the plan"},
+		{"config of others", 1, gin.H{"planId": 1, "configId": 12}, nil, http.StatusBadRequest, "you are not the owner of the config"},
+		{"duplicate", 1, gin.H{"planId": 1, "configId": 2}, store.ErrDuplicate, http.StatusConflict,
+			"this config already added to the plan"},
+		{"database error", 1, gin.H{"planId": 1, "configId": 2}, errors.New("database is down"), http.StatusBadGateway,
+			"database is down"},
+	}
+	for _, c := range cases {
+		plans := &stubPlans{err: c.err}
+		h := &Handler{store: &store.Store{Plans: plans, Configs: stubConfigs{}}}
+		code, msg := serve(t, h, (*Handler).planAddConfig, c.userID, c.req)
+		if code != c.want || msg != c.wantMsg {
+			t.Errorf("%s: status %d, message %q, want %d, %q", c.name, code, msg, c.want, c.wantMsg)
+		}
+		if added := len(plans.added) == 1; added != (c.want == http.StatusOK) {
+			t.Errorf("%s: relations added %v", c.name, plans.added)
+		}
+	}
+}
+
+func TestPlanCreateTokenLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	h := &Handler{store: &store.Store{Plans: &stubPlans{err: store.ErrLimitExceeded}}}
+	if code, _ := serve(t, h, (*Handler).planCreateToken, 1, gin.H{"id": 1}); code != http.StatusBadRequest {
+		t.Errorf("status %d, want %d", code, http.StatusBadRequest)
+	}
+}
@@ -1,35 +1,88 @@
 package routers
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/middlewares"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
 )
 
+// Handler holds the dependencies of routers, every router is a method of Handler
+type Handler struct {
+	store *store.Store
+}
+
 // Router type alias function to handle some request
 type Router struct {
 	path   string
 	method string
-	f      func(*gin.Context)
+	scope  string
+	f      func(*Handler, *gin.Context)
 }
 
 var routers = make([]Router, 0)
 
-// RegisterRouter function store the router to register to Gin temporarily
-func RegisterRouter(path string, method string, f func(*gin.Context)) {
-	routers = append(routers, Router{path: path, method: method, f: f})
+// scopeNone is the scope of routers open to any api key, e: login and generate by token
+const scopeNone = "none"
+
+// RegisterRouter function store the router to register to Gin temporarily,
+// f is usually a method expression of Handler like (*Handler).login
+//
+// scope is required for api key to access the router, one of middlewares.Scopes or scopeNone.
+// Api keys are rejected by the router with empty or unknown scope.
+func RegisterRouter(path string, method string, scope string, f func(*Handler, *gin.Context)) {
+	routers = append(routers, Router{path: path, method: method, scope: scope, f: f})
 }
 
 // Init register all stored router to Gin
-// This request a *gin.Engine initialized in server package
-func Init(engine *gin.Engine) error {
+// This request a *gin.Engine initialized in server package,
+// and the stores used by routers
+func Init(engine *gin.Engine, st *store.Store) error {
+	h := &Handler{store: st}
 	routerGroup := engine.Group(config.HTTPBasepath)
 	for _, r := range routers {
 		switch r.method {
 		case "get":
-			routerGroup.GET(r.path, r.f)
+			routerGroup.GET(r.path, requireScope(r.scope), h.bind(r.f))
 		case "post":
-			routerGroup.POST(r.path, r.f)
+			routerGroup.POST(r.path, requireScope(r.scope), h.bind(r.f))
 		}
 	}
 	return nil
 }
+
+// bind the router to handler, so it could be registered to Gin.
+// The router is called with the stores bound to the request context
+func (h *Handler) bind(f func(*Handler, *gin.Context)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f(&Handler{store: h.store.WithContext(c.Request.Context())}, c)
+	}
+}
+
+// requireScope abort the request authorized by api key without the scope,
+// the request is always aborted if scope is not a known one, so a router without scope is closed to api key
+func requireScope(scope string) gin.HandlerFunc {
+	known := scope == scopeNone
+	for _, s := range middlewares.Scopes {
+		known = known || s == scope
+	}
+	return func(c *gin.Context) {
+		if scope == scopeNone {
+			return
+		}
+		if !known {
+			if _, byAPIKey := c.Get(middlewares.Key.Scopes); byAPIKey {
+				c.AbortWithStatusJSON(http.StatusForbidden, dto.NewResponseBad("api key is not allowed for this api"))
+			}
+			return
+		}
+		if !middlewares.HasScope(c, scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden,
+				dto.NewResponseBad(fmt.Sprintf("scope %q is required for this api key", scope)))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package routers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/middlewares"
+)
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name   string
+		scope  string
+		scopes []string // nil for the request authorized by cookie
+		want   int
+	}{
+		{"cookie", middlewares.ScopeAdmin, nil, http.StatusOK},
+		{"exact scope", middlewares.ScopeConfigs, []string{middlewares.ScopeConfigs}, http.StatusOK},
+		{"admin has all scopes", middlewares.ScopePlans, []string{middlewares.ScopeAdmin}, http.StatusOK},
+		{"every key could read", middlewares.ScopeRead, []string{middlewares.ScopeConfigs}, http.StatusOK},
+		{"scope missing", middlewares.ScopePlans, []string{middlewares.ScopeConfigs}, http.StatusForbidden},
+		{"admin scope missing", middlewares.ScopeAdmin, []string{middlewares.ScopePlans}, http.StatusForbidden},
+		{"open to any key", scopeNone, []string{middlewares.ScopeRead}, http.StatusOK},
+
+		// the router registered without scope is closed to api key, but not cookie
+		{"no scope declared", "", []string{middlewares.ScopeAdmin}, http.StatusForbidden},
+		{"unknown scope declared", "plan", []string{middlewares.ScopeAdmin}, http.StatusForbidden},
+		{"no scope declared by cookie", "", nil, http.StatusOK},
+	}
+	for _, c := range cases {
+		engine := gin.New()
+		engine.POST("/", func(ctx *gin.Context) {
+			if c.scopes != nil {
+				ctx.Set(middlewares.Key.Scopes, c.scopes)
+			}
+		}, requireScope(c.scope), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+		if w.Code != c.want {
+			t.Errorf("%s: status %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+}
@@ -1,15 +1,14 @@
 package routers
 
 import (
-	"database/sql"
 	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/leafee98/class-schedule-to-icalendar-restserver/db"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/middlewares"
-	"github.com/sirupsen/logrus"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/validation"
 )
 
 func getUserIDOrAbort(c *gin.Context, userID *int64) error {
@@ -31,6 +30,18 @@ func checkConfigFormatRange(r int8) bool {
 	return r <= dto.LimitConfigFormatMax && r >= dto.LimitConfigFormatMin
 }
 
+// check the type, the structure of content in its format and the options by schema,
+// respond with every field error found if the content is invalid
+func configContentValidOrAbort(c *gin.Context, content string, format int8, configType int8) error {
+	errs := validation.Validate(content, format, configType)
+	if len(errs) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(
+			dto.ConfigInvalidRes{Message: "invalid config content", Errors: errs}))
+		return errors.New("invalid config content")
+	}
+	return nil
+}
+
 func bindOrAbort(c *gin.Context, req interface{}) error {
 	err := c.ShouldBind(req)
 	if err != nil {
@@ -43,232 +54,96 @@ func bindOrAbort(c *gin.Context, req interface{}) error {
 /////// Database Utility //////
 ///////////////////////////////
 
-///////// Plan Part ///////////
-
-// return nil if plan exist.
-func planExist(planID int64) error {
-	row := db.DB.QueryRow("select c_id from t_plan where c_id = ? and c_deleted = false", planID)
-	return row.Scan(&planID)
-}
-
-func planExistOrAbort(c *gin.Context, planID int64) error {
-	err := planExist(planID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("the plan not exist"))
-		} else {
-			logrus.Error(err.Error())
-			c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
-		}
-	}
-	return err
-}
-
-// return nil if the plan belongs to the user
-func planOwnerShip(planID int64, userID int64) error {
-	var userIDInDB int64
-	row := db.DB.QueryRow("select c_owner_id from t_plan where c_id = ? and c_deleted = false", planID)
-	err := row.Scan(&userIDInDB)
-	if err == sql.ErrNoRows {
-		return errors.New("the plan not exist")
-	} else if err != nil {
-		return err
-	}
-	if userIDInDB != userID {
-		return errors.New("you are not the owner of the plan")
-	}
-	return nil
-}
-
-func planOwnershipOrAbort(c *gin.Context, planID int64, userID int64) error {
-	err := planOwnerShip(planID, userID)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(err.Error()))
+// abort with the message of err if err is store.ErrNotFound, otherwise abort as database error
+func storeErrorAbort(c *gin.Context, err error, notFoundMsg string) {
+	if err == store.ErrNotFound {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(notFoundMsg))
+	} else {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
 	}
-	return err
 }
 
-//////// Config Part //////////
-
-// return nil if the config exist
-func configExist(configID int64) error {
-	row := db.DB.QueryRow("select c_id from t_plan where c_id = ? and c_deleted = false", configID)
-	return row.Scan(&configID)
-}
+///////// Plan Part ///////////
 
-func configExistOrAbort(c *gin.Context, configID int64) error {
-	err := configExist(configID)
+// return nil if the plan exists and belongs to the user
+func (h *Handler) planOwnershipOrAbort(c *gin.Context, planID int64, userID int64) error {
+	ownerID, err := h.store.Plans.Owner(planID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("the config not exist"))
-		} else {
-			logrus.Error(err.Error())
-			c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
-		}
-	}
-	return err
-}
-
-// return nil if the config belongs to the user
-func configOwnership(configID int64, userID int64) error {
-	var userIDInDB int64
-	row := db.DB.QueryRow("select c_owner_id from t_config where c_id = ? and c_deleted = false", configID)
-	err := row.Scan(&userIDInDB)
-	if err == sql.ErrNoRows {
-		return errors.New("the config not exist")
-	} else if err != nil {
+		storeErrorAbort(c, err, "the plan not exist")
 		return err
 	}
-	if userIDInDB != userID {
-		return errors.New("you are not the owner of the plan")
+	if ownerID != userID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("you are not the owner of the plan"))
+		return errors.New("not owner")
 	}
 	return nil
 }
 
-func configOwnershipOrAbort(c *gin.Context, configID int64, userID int64) error {
-	err := configOwnership(configID, userID)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(err.Error()))
-	}
-	return err
-}
-
-/////// Relation Part /////////
-
-// return nil if exist
-func relationExist(planID int64, configID int64) error {
-	var cnt int64
-	row := db.DB.QueryRow("select count(*) as cnt from t_plan_config_relation"+
-		" where c_plan_id = ? and c_config_id = ?", planID, configID)
-	err := row.Scan(&cnt)
+//////// Config Part //////////
 
-	// sql.ErrNoRows will never occur
+// return nil if the config exists and belongs to the user
+func (h *Handler) configOwnershipOrAbort(c *gin.Context, configID int64, userID int64) error {
+	ownerID, _, err := h.store.Configs.Owner(configID)
 	if err != nil {
+		storeErrorAbort(c, err, "the config not exist")
 		return err
-	} else {
-		if cnt > 0 {
-			return nil
-		} else {
-			return errors.New("no such relation")
-		}
 	}
-}
-
-///// Relation share Part /////
-
-// return nil if exist
-func relationShareExist(planID int64, configShareID int64) error {
-	var cnt int64
-	row := db.DB.QueryRow("select count(*) as cnt from t_plan_config_share_relation "+
-		"where c_plan_id = ? and c_config_share_id = ?", planID, configShareID)
-	err := row.Scan(&cnt)
-
-	// sql.ErrNoRows will never occur
-	if err != nil {
-		return err
-	} else {
-		if cnt > 0 {
-			return nil
-		} else {
-			return errors.New("no such relation")
-		}
+	if ownerID != userID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("you are not the owner of the config"))
+		return errors.New("not owner")
 	}
+	return nil
 }
 
 ////// Config Share Part //////
 
-func configShareExist(configShareID int64) error {
-	var count int64
-	row := db.DB.QueryRow("select count(*) from t_config_share where c_deleted = false and c_id = ?;", configShareID)
-	if err := row.Scan(&count); err != nil {
-		return err
-	} else {
-		if count > 0 {
-			return nil
-		} else {
-			return errors.New("config share not exist or has been deleted")
-		}
+func (h *Handler) configShareExistOrAbort(c *gin.Context, configShareID int64) error {
+	exist, err := h.store.Shares.ConfigShareExist(configShareID)
+	if err == nil && !exist {
+		err = store.ErrNotFound
 	}
-}
-
-func configShareExistOrAbort(c *gin.Context, configShareID int64) error {
-	err := configShareExist(configShareID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(err.Error()))
+		storeErrorAbort(c, err, "config share not exist or has been deleted")
 	}
 	return err
 }
 
-func configShareOwnership(configShareID int64, userID int64) error {
-	var dbUserID int64
-	const sqlCommand string = "select c_owner_id from t_config where c_deleted = false and c_id = " +
-		"(select c_config_id from t_config_share where c_deleted = false and c_id = ?);"
-	row := db.DB.QueryRow(sqlCommand, configShareID)
-	err := row.Scan(&dbUserID)
-	if err == sql.ErrNoRows {
-		return errors.New("the config share doesn't exist")
-	} else if err != nil {
+func (h *Handler) configShareOwnershipOrAbort(c *gin.Context, configShareID int64, userID int64) error {
+	ownerID, err := h.store.Shares.ConfigShareOwner(configShareID)
+	if err != nil {
+		storeErrorAbort(c, err, "the config share doesn't exist")
 		return err
 	}
-	if dbUserID != userID {
-		return errors.New("you are not the owner of the config's share")
+	if ownerID != userID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("you are not the owner of the config's share"))
+		return errors.New("not owner")
 	}
 	return nil
 }
 
-func configShareOwnershipOrAbort(c *gin.Context, configShareID int64, userID int64) error {
-	err := configShareOwnership(configShareID, userID)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(err.Error()))
-	}
-	return err
-}
-
 /////// Plan Share Part ///////
 
-func planShareExist(configShareID int64) error {
-	var count int64
-	row := db.DB.QueryRow("select count(*) from t_plan_share where c_deleted = false and c_id = ?;", configShareID)
-	if err := row.Scan(&count); err != nil {
-		return err
-	} else {
-		if count > 0 {
-			return nil
-		} else {
-			return errors.New("plan share not exist or has been deleted")
-		}
+func (h *Handler) planShareExistOrAbort(c *gin.Context, planShareID int64) error {
+	exist, err := h.store.Shares.PlanShareExist(planShareID)
+	if err == nil && !exist {
+		err = store.ErrNotFound
 	}
-}
-
-func planShareExistOrAbort(c *gin.Context, configShareID int64) error {
-	err := planShareExist(configShareID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(err.Error()))
+		storeErrorAbort(c, err, "plan share not exist or has been deleted")
 	}
 	return err
 }
 
-func planShareOwnership(configShareID int64, userID int64) error {
-	var dbUserID int64
-	const sqlCommand string = "select c_owner_id from t_plan where c_deleted = false and c_id = " +
-		"(select c_plan_id from t_plan_share where c_deleted = false and c_id = ?);"
-	row := db.DB.QueryRow(sqlCommand, configShareID)
-	err := row.Scan(&dbUserID)
-	if err == sql.ErrNoRows {
-		return errors.New("the config share doesn't exist")
-	} else if err != nil {
+func (h *Handler) planShareOwnershipOrAbort(c *gin.Context, planShareID int64, userID int64) error {
+	ownerID, err := h.store.Shares.PlanShareOwner(planShareID)
+	if err != nil {
+		storeErrorAbort(c, err, "the plan share doesn't exist")
 		return err
 	}
-	if dbUserID != userID {
-		return errors.New("you are not the owner of the config's share")
+	if ownerID != userID {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("you are not the owner of the plan's share"))
+		return errors.New("not owner")
 	}
 	return nil
 }
-
-func planShareOwnershipOrAbort(c *gin.Context, configShareID int64, userID int64) error {
-	err := planShareOwnership(configShareID, userID)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(err.Error()))
-	}
-	return err
-}
@@ -1,81 +1,79 @@
 package routers
 
 import (
-	"crypto/sha256"
-	"database/sql"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/leafee98/class-schedule-to-icalendar-restserver/db"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/middlewares"
-	"github.com/sirupsen/logrus"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/password"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
 )
 
 // contains routers relative to user's account
 
 // run automatically to register routers in this file
 func init() {
-	RegisterRouter("/register", "post", register)
-	RegisterRouter("/login", "post", login)
-	RegisterRouter("/logout", "post", logout)
-	RegisterRouter("/logout", "get", logout)
+	RegisterRouter("/register", "post", scopeNone, (*Handler).register)
+	RegisterRouter("/login", "post", scopeNone, (*Handler).login)
+	RegisterRouter("/logout", "post", scopeNone, (*Handler).logout)
+	RegisterRouter("/logout", "get", scopeNone, (*Handler).logout)
+	RegisterRouter("/logout-all", "post", middlewares.ScopeAdmin, (*Handler).logoutAll)
+
+	RegisterRouter("/session-list", "post", middlewares.ScopeAdmin, (*Handler).sessionList)
+	RegisterRouter("/session-revoke", "post", middlewares.ScopeAdmin, (*Handler).sessionRevoke)
+
+	RegisterRouter("/api-key-create", "post", middlewares.ScopeAdmin, (*Handler).apiKeyCreate)
+	RegisterRouter("/api-key-revoke", "post", middlewares.ScopeAdmin, (*Handler).apiKeyRevoke)
+	RegisterRouter("/api-key-list", "post", middlewares.ScopeAdmin, (*Handler).apiKeyList)
 }
 
-func register(c *gin.Context) {
+func (h *Handler) register(c *gin.Context) {
 	var req dto.UserRegisterReq
 	if bindOrAbort(c, &req) != nil {
 		return
 	}
 
-	var cnt int64
-	err := db.DB.Get(&cnt, "select count(c_id) from t_user where c_username = ? or c_email = ?",
-		req.Username, req.Email)
+	cnt, err := h.store.Users.CountByUsernameOrEmail(req.Username, req.Email)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad(err.Error()))
-		logrus.Errorf("err while register: %v", err.Error())
+		middlewares.Logger(c).Errorf("err while register: %v", err.Error())
 		return
 	}
 	if cnt > 0 {
 		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("duplicated username or email"))
-		logrus.Warnf("err while register: %s", "duplicated username or email")
+		middlewares.Logger(c).Warnf("err while register: %s", "duplicated username or email")
 		return
 	}
 
-	// file the hash password
-	var hashPassArr [32]byte = passwordHash(req.PasswordPlain)
-	req.Password = hashPassArr[:]
-
-	res, err := db.DB.Exec("insert into t_user (c_username, c_password, c_email, c_nickname) "+
-		"values (?, ?, ?, ?)", req.Username, req.Password, req.Email, req.Email)
-
+	// fill the hash password
+	hashed, err := password.Hash(req.PasswordPlain)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, dto.NewResponseBad(err.Error()))
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
+		return
 	}
+	req.Password = []byte(hashed)
 
-	id, err := res.LastInsertId()
+	id, err := h.store.Users.Create(req.Username, req.Password, req.Email, req.Nickname)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, dto.NewResponseBad(err.Error()))
-		logrus.Error(err)
+		middlewares.Logger(c).Error(err)
 	} else {
 		c.JSON(http.StatusOK, dto.NewResponseFine(dto.UserRegisterRes{ID: id}))
 	}
 }
 
-func login(c *gin.Context) {
+func (h *Handler) login(c *gin.Context) {
 	var req dto.UserLoginReq
 	if bindOrAbort(c, &req) != nil {
 		return
 	}
 
-	var dbID int64
-	var dbPassword [32]byte
-	row := db.DB.QueryRow("select c_id, c_password from t_user where c_username = ?", req.Username)
-
-	var tmp []byte
-	switch err := row.Scan(&dbID, &tmp); err {
-	case sql.ErrNoRows:
+	dbID, dbPassword, err := h.store.Users.GetPassword(req.Username)
+	switch err {
+	case store.ErrNotFound:
 		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("user not exists"))
 		return
 	case nil:
@@ -85,12 +83,26 @@ func login(c *gin.Context) {
 		return
 	}
 
-	copy(dbPassword[:], tmp)
+	ok, needRehash, err := password.Verify(req.PasswordPlain, dbPassword)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, dto.NewResponseBad(err.Error()))
+		return
+	}
+
+	if ok {
+		// upgrade the legacy or outdated hash, failure here should not block login
+		if needRehash {
+			h.rehashPassword(c, dbID, req.PasswordPlain)
+		}
 
-	var passCipher [32]byte = passwordHash(req.PasswordPlain)
-	if passCipher == dbPassword {
 		// logdin success, register token and set cookie
-		token := middlewares.RegisterToken(dbID, req.TokenDuration)
+		token, err := middlewares.RegisterToken(h.store.Tokens, dbID, req.TokenDuration, c.Request.UserAgent(), middlewares.ClientIP(c))
+		if err != nil {
+			middlewares.Logger(c).Error(err)
+			c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+			return
+		}
 		c.SetSameSite(http.SameSiteStrictMode)
 		c.SetCookie("token", token, 3600*24*req.TokenDuration, "/", "", false, false)
 		c.JSON(http.StatusOK, dto.NewResponseFine(dto.UserLoginRes{ID: dbID}))
@@ -99,17 +111,178 @@ func login(c *gin.Context) {
 	}
 }
 
-func logout(c *gin.Context) {
+func (h *Handler) logout(c *gin.Context) {
 	token, err := c.Cookie("token")
 	c.SetSameSite(http.SameSiteStrictMode)
 	c.SetCookie("token", "000", -1, "/", "", false, false)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("unauthorized logout is forbidden"))
+		return
+	}
+	if err = h.store.Tokens.RevokeSessionByToken(token); err != nil {
+		middlewares.Logger(c).Error(err)
+	}
+}
+
+// remove all login sessions of user, include the current one
+func (h *Handler) logoutAll(c *gin.Context) {
+	var userID int64
+	if getUserIDOrAbort(c, &userID) != nil {
+		return
+	}
+
+	if err := h.store.Tokens.RevokeAllSessions(userID); err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie("token", "000", -1, "/", "", false, false)
+	c.JSON(http.StatusOK, dto.NewResponseFine(dto.LogoutAllRes("ok")))
+}
+
+// list all login sessions of user, expired ones excluded
+func (h *Handler) sessionList(c *gin.Context) {
+	var userID int64
+	if getUserIDOrAbort(c, &userID) != nil {
+		return
+	}
+	currentID, _ := c.Get(middlewares.Key.SessionID)
+
+	sessions, err := h.store.Tokens.ListSessions(userID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	c.JSON(http.StatusOK, dto.NewResponseFine(dto.SessionListRes{Sessions: sessions}))
+}
+
+// revoke one login session of user
+func (h *Handler) sessionRevoke(c *gin.Context) {
+	var req dto.SessionRevokeReq
+	if bindOrAbort(c, &req) != nil {
+		return
+	}
+
+	var userID int64
+	if getUserIDOrAbort(c, &userID) != nil {
+		return
+	}
+
+	revoked, err := h.store.Tokens.RevokeSession(userID, req.ID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	}
+	if !revoked {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("no such session"))
+		return
+	}
+	c.JSON(http.StatusOK, dto.NewResponseFine(dto.SessionRevokeRes("ok")))
+}
+
+// create an api key used in Authorization header as bearer token
+//
+// check login status
+// check scopes are all available, err msg: "invalid scope"
+func (h *Handler) apiKeyCreate(c *gin.Context) {
+	var req dto.APIKeyCreateReq
+	if bindOrAbort(c, &req) != nil {
+		return
+	}
+
+	var userID int64
+	if getUserIDOrAbort(c, &userID) != nil {
+		return
 	}
-	middlewares.ExpireToken(token)
+
+	if len(req.Scopes) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("invalid scope"))
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !checkAPIKeyScope(scope) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("invalid scope: "+scope))
+			return
+		}
+	}
+
+	id, key, err := middlewares.RegisterAPIKey(h.store.Tokens, userID, req.Name, req.Scopes, req.ExpireDays)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, dto.NewResponseFine(dto.APIKeyCreateRes{ID: id, Key: key}))
 }
 
-// passwordHash do sha256 as hash to avoid using plain text
-func passwordHash(p string) [32]byte {
-	return sha256.Sum256([]byte(p))
+func (h *Handler) apiKeyRevoke(c *gin.Context) {
+	var req dto.APIKeyRevokeReq
+	if bindOrAbort(c, &req) != nil {
+		return
+	}
+
+	var userID int64
+	if getUserIDOrAbort(c, &userID) != nil {
+		return
+	}
+
+	revoked, err := h.store.Tokens.RevokeAPIKey(userID, req.ID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	}
+	if !revoked {
+		c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewResponseBad("no such api key"))
+		return
+	}
+	c.JSON(http.StatusOK, dto.NewResponseFine(dto.APIKeyRevokeRes("ok")))
+}
+
+// list all api keys of user, the key itself is not included
+func (h *Handler) apiKeyList(c *gin.Context) {
+	var userID int64
+	if getUserIDOrAbort(c, &userID) != nil {
+		return
+	}
+
+	keys, err := h.store.Tokens.ListAPIKeys(userID)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		c.AbortWithStatusJSON(http.StatusBadGateway, dto.NewResponseBad(err.Error()))
+		return
+	}
+	for i := range keys {
+		keys[i].ScopeList = strings.Split(keys[i].Scopes, ",")
+	}
+	c.JSON(http.StatusOK, dto.NewResponseFine(dto.APIKeyListRes{Keys: keys}))
+}
+
+func checkAPIKeyScope(scope string) bool {
+	for _, s := range middlewares.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// rehashPassword replace the stored password hash with the one of current hasher
+func (h *Handler) rehashPassword(c *gin.Context, userID int64, plain string) {
+	hashed, err := password.Hash(plain)
+	if err != nil {
+		middlewares.Logger(c).Error(err)
+		return
+	}
+	if err = h.store.Users.UpdatePassword(userID, []byte(hashed)); err != nil {
+		middlewares.Logger(c).Error(err)
+		return
+	}
+	middlewares.Logger(c).Infof("password hash of userID=%v upgraded", userID)
 }
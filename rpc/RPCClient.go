@@ -2,28 +2,241 @@ package rpc
 
 import (
 	"context"
+	"math/rand"
 	"time"
 
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/metrics"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/rpc/CSTIRPC"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/tracing"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// requestIDKey is the metadata key carrying the request ID to rpc server
+const requestIDKey = "x-request-id"
+
+// the delay before the first retry, doubled for each later retry up to retryMaxDelay
+const retryBaseDelay = 100 * time.Millisecond
+const retryMaxDelay = 2 * time.Second
+
+var conn *grpc.ClientConn
 var client CSTIRPC.CSTIRpcServerClient
 
-// JSONGenerate generate the icalendar result, request string and return string and error
-func JSONGenerate(content string) (string, error) {
-	res, err := client.JsonGenerate(context.TODO(), &CSTIRPC.ConfJson{Content: content})
-	return res.GetContent(), err
+var timeout time.Duration
+var maxRetries int
+var cb *breaker
+
+// JSONGenerate generate the icalendar result, request string and return string and error.
+// The call is canceled with ctx, usually the context of http request, and bounded by rpc-timeout.
+// Transient failures are retried with backoff, and *CircuitOpenError is returned without calling
+// the rpc server if it keeps failing.
+// The call is traced as a child of the span in ctx, and the trace context is sent to rpc server.
+func JSONGenerate(ctx context.Context, content string) (res string, err error) {
+	ctx, span := tracing.Start(ctx, "rpcserver.CSTIRpcServer/jsonGenerate", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.RPCSystemGRPC,
+			semconv.RPCService("rpcserver.CSTIRpcServer"), semconv.RPCMethod("jsonGenerate")))
+	defer func() {
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+	ctx = withTraceContext(ctx)
+
+	if err := cb.allow(); err != nil {
+		metrics.RPCGenerateRequests.WithLabelValues("CircuitOpen").Inc()
+		return "", err
+	}
+
+	start := time.Now()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var ical *CSTIRPC.ResultIcal
+	for attempt := 0; ; attempt++ {
+		ical, err = client.JsonGenerate(ctx, &CSTIRPC.ConfJson{Content: content})
+		if err == nil || !retryable(err) || attempt >= maxRetries {
+			break
+		}
+		span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt+1),
+			attribute.String("error", err.Error())))
+		logrus.WithField("request_id", requestIDOf(ctx)).
+			Warnf("generate failed, retry %d/%d: %v", attempt+1, maxRetries, err)
+		if !sleep(ctx, retryDelay(attempt)) {
+			break
+		}
+	}
+
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	metrics.RPCGenerateRequests.WithLabelValues(code.String()).Inc()
+	metrics.RPCGenerateDuration.WithLabelValues(code.String()).Observe(time.Since(start).Seconds())
+
+	cb.done(outcomeOf(err))
+	return ical.GetContent(), err
+}
+
+// WithRequestID attach the request ID to ctx, which is sent to rpc server
+// as metadata "x-request-id" by the calls with ctx
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, requestIDKey, id)
+}
+
+// withTraceContext attach the trace context of the span in ctx to the outgoing metadata,
+// so the spans of rpc server join the trace
+func withTraceContext(ctx context.Context) context.Context {
+	md := metadata.MD{}
+	tracing.Inject(ctx, metadataCarrier(md))
+	for k, v := range md {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v[0])
+	}
+	return ctx
+}
+
+// metadataCarrier adapt metadata to the carrier of propagator
+type metadataCarrier metadata.MD
+
+func (m metadataCarrier) Get(key string) string {
+	if v := metadata.MD(m).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (m metadataCarrier) Set(key string, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+func (m metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// requestIDOf return the request ID attached by WithRequestID, empty if not attached
+func requestIDOf(ctx context.Context) string {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if ids := md.Get(requestIDKey); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
 }
 
-// Init initialize RPCClient object to use rpc of rpcserver
-// try to connect to rpc server in 5 seconds
-func Init() error {
-	conn, err := grpc.Dial(config.RPCTarget, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+// Init initialize RPCClient object to use rpc of rpcserver.
+// The connection is established in background and re-established when lost,
+// so the rest server could start before rpc server.
+// opts are appended to the default dial options, e: a custom dialer in tests
+func Init(opts ...grpc.DialOption) error {
+	timeout = time.Duration(config.RPCTimeout) * time.Second
+	maxRetries = config.RPCMaxRetries
+	cb = newBreaker(config.RPCBreakerThreshold, time.Duration(config.RPCBreakerCooldown)*time.Second)
+
+	// the default max delay of reconnecting is 2 minutes, too long for a restarted rpc server
+	reconnect := grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: 5 * time.Second}
+	reconnect.Backoff.MaxDelay = 10 * time.Second
+
+	transport, err := transportOption()
+	if err != nil {
+		return err
+	}
+
+	target, poolOpts := poolOptions(config.RPCTarget, config.RPCHealthService)
+	dialOpts := append([]grpc.DialOption{transport, grpc.WithConnectParams(reconnect)}, poolOpts...)
+	conn, err = grpc.Dial(target, append(dialOpts, opts...)...)
 	if err != nil {
 		return err
 	}
 	client = CSTIRPC.NewCSTIRpcServerClient(conn)
+	go watchState(conn)
 	return nil
 }
+
+// State return the state of connection to rpc server, it is ready if any backend is connected
+func State() connectivity.State {
+	if conn == nil {
+		return connectivity.Shutdown
+	}
+	return conn.GetState()
+}
+
+// Close close the connection to rpc server
+func Close() error {
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// log the change of connection state, the reconnecting is done by grpc itself
+func watchState(conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for conn.WaitForStateChange(context.Background(), state) {
+		state = conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			logrus.Infof("rpc server %s connected", config.RPCTarget)
+		case connectivity.TransientFailure:
+			logrus.Warnf("rpc server %s is unreachable, reconnecting in background", config.RPCTarget)
+		case connectivity.Shutdown:
+			return
+		}
+	}
+}
+
+// retryable return true if the call may succeed when retried,
+// e: the rpc server is restarting or overloaded
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// outcomeOf tell the circuit breaker how the call ended, it is failed if err means the
+// rpc server is not working, and abandoned if canceled by client.
+// errors of the request itself mean the rpc server is working
+func outcomeOf(err error) outcome {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return failed
+	case codes.Canceled:
+		return abandoned
+	}
+	return succeeded
+}
+
+// exponential backoff with jitter, so the retries from many requests won't arrive together
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << uint(attempt)
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// sleep for d, return false if ctx is done before that
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
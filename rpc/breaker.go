@@ -0,0 +1,93 @@
+package rpc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitOpenError is returned without calling the rpc server when the circuit breaker is open
+type CircuitOpenError struct {
+	// RetryAfter is the time left before the rpc server is called again
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("rpc server is unavailable, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// breaker stop calling the rpc server for cooldown after threshold consecutive failures.
+// After cooldown one call is let through as probe, the breaker close if it succeed
+// and open again if it fail. A nil breaker or zero threshold never open.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow return *CircuitOpenError if the call should not be made
+func (b *breaker) allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Before(b.openUntil) || b.probing {
+		retryAfter := b.openUntil.Sub(now)
+		if retryAfter < time.Second {
+			retryAfter = time.Second
+		}
+		return &CircuitOpenError{RetryAfter: retryAfter}
+	}
+	b.probing = true
+	return nil
+}
+
+// outcome is how an allowed call ended
+type outcome int
+
+const (
+	succeeded outcome = iota
+	failed
+
+	// abandoned is the call ended by caller before the rpc server answered, e: the request
+	// is canceled. It tells nothing about the rpc server, so neither close nor open the breaker
+	abandoned
+)
+
+// done record the outcome of an allowed call.
+// An abandoned probe let the next call probe again
+func (b *breaker) done(o outcome) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch o {
+	case succeeded:
+		b.failures = 0
+		return
+	case abandoned:
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
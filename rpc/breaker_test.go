@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// open the breaker and wait the cooldown, so the next call is the probe
+func halfOpen(t *testing.T) *breaker {
+	t.Helper()
+	b := newBreaker(2, 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatal(err)
+		}
+		b.done(failed)
+	}
+	if err := b.allow(); err == nil {
+		t.Fatal("breaker is not open after threshold failures")
+	}
+	time.Sleep(20 * time.Millisecond)
+	return b
+}
+
+func TestBreakerAbandonedProbe(t *testing.T) {
+	b := halfOpen(t)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe after cooldown: %v", err)
+	}
+	if err := b.allow(); err == nil {
+		t.Fatal("second call is allowed while probing")
+	}
+
+	// the probe canceled by client doesn't close the breaker, the next call probe again
+	b.done(outcomeOf(status.FromContextError(context.Canceled).Err()))
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe after abandoned probe: %v", err)
+	}
+	if err := b.allow(); err == nil {
+		t.Fatal("breaker is closed by abandoned probe")
+	}
+
+	b.done(succeeded)
+	if err := b.allow(); err != nil {
+		t.Fatalf("call after succeeded probe: %v", err)
+	}
+}
+
+func TestBreakerFailedProbe(t *testing.T) {
+	b := halfOpen(t)
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe after cooldown: %v", err)
+	}
+	b.done(failed)
+	var open *CircuitOpenError
+	if err := b.allow(); !errors.As(err, &open) {
+		t.Fatalf("call after failed probe: %v, want *CircuitOpenError", err)
+	}
+}
+
+func TestOutcomeOf(t *testing.T) {
+	cases := []struct {
+		err  error
+		want outcome
+	}{
+		{nil, succeeded},
+		{status.Error(codes.InvalidArgument, "bad envelope"), succeeded},
+		{status.Error(codes.Unavailable, "down"), failed},
+		{status.Error(codes.DeadlineExceeded, "timeout"), failed},
+		{status.FromContextError(context.Canceled).Err(), abandoned},
+	}
+	for _, c := range cases {
+		if got := outcomeOf(c.err); got != c.want {
+			t.Errorf("outcomeOf(%v) = %d, want %d", c.err, got, c.want)
+		}
+	}
+}
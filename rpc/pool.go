@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+
+	// for the side effect of registering the client of grpc health checking protocol
+	_ "google.golang.org/grpc/health"
+)
+
+// scheme of the resolver serving the address list in rpc-target
+const staticScheme = "csti-static"
+
+// calls are balanced across the backends by round robin, and the backends failing the
+// health checking are ejected until they become serving again. The backends which don't
+// implement the health service are treated as healthy.
+const serviceConfig = `{
+	"loadBalancingConfig": [{"round_robin": {}}],
+	"healthCheckConfig": {"serviceName": %q}
+}`
+
+// poolOptions return the target to dial and the options to balance calls across its backends.
+// target is a comma separated list of addresses, e: "10.0.0.1:8047,10.0.0.2:8047",
+// or a single target of grpc naming, e: "dns:///rpc.example.com:8047" resolving to many addresses.
+// healthService is the service name checked by grpc health checking protocol, empty for the whole server.
+func poolOptions(target string, healthService string) (string, []grpc.DialOption) {
+	opts := []grpc.DialOption{grpc.WithDefaultServiceConfig(fmt.Sprintf(serviceConfig, healthService))}
+
+	addrs := splitTarget(target)
+	if len(addrs) <= 1 {
+		return strings.TrimSpace(target), opts
+	}
+
+	// the certificate of each backend is verified against its own host instead of the dial target,
+	// unless rpc-tls-server-name is set
+	var state resolver.State
+	for _, addr := range addrs {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: addr, ServerName: host})
+	}
+	r := manual.NewBuilderWithScheme(staticScheme)
+	r.InitialState(state)
+	return staticScheme + ":///pool", append(opts, grpc.WithResolvers(r))
+}
+
+// split the comma separated addresses, the empty ones are ignored
+func splitTarget(target string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(target, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// waitServed call generate until each of names has served a call, or fail the test after a while
+func waitServed(t *testing.T, names ...string) {
+	t.Helper()
+	served := map[string]bool{}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		name, err := generate(t)
+		if err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		served[name] = true
+
+		all := true
+		for _, n := range names {
+			all = all && served[n]
+		}
+		if all {
+			return
+		}
+	}
+	t.Fatalf("expect %v serving, got %v", names, served)
+}
+
+// TestPoolFailover check the calls keep succeeding on the other backend after one is stopped
+func TestPoolFailover(t *testing.T) {
+	a := startBackend(t, "a")
+	b := startBackend(t, "b")
+	initClient(t, a.addr+", "+b.addr)
+	waitServed(t, "a", "b")
+
+	b.server.Stop()
+	for i := 0; i < 20; i++ {
+		name, err := generate(t)
+		if err != nil {
+			t.Fatalf("generate after backend b stopped: %v", err)
+		}
+		if name != "a" {
+			t.Fatalf("expect served by a, got %s", name)
+		}
+	}
+}
+
+// TestPoolHealthCheck check the backend not serving the health service is ejected,
+// and joins the pool again once serving
+func TestPoolHealthCheck(t *testing.T) {
+	config.RPCHealthService = "rpc-health-service"
+	t.Cleanup(func() { config.RPCHealthService = "" })
+
+	a := startBackend(t, "a")
+	b := startBackend(t, "b")
+	a.health.SetServingStatus(config.RPCHealthService, healthpb.HealthCheckResponse_SERVING)
+	b.health.SetServingStatus(config.RPCHealthService, healthpb.HealthCheckResponse_SERVING)
+	initClient(t, a.addr+","+b.addr)
+	waitServed(t, "a", "b")
+
+	b.health.SetServingStatus(config.RPCHealthService, healthpb.HealthCheckResponse_NOT_SERVING)
+	// the status is watched in stream, wait it reaching the client by a run of calls served by a
+	deadline := time.Now().Add(5 * time.Second)
+	for run := 0; run < 20; {
+		if time.Now().After(deadline) {
+			t.Fatal("expect b ejected while not serving")
+		}
+		name, err := generate(t)
+		if err != nil {
+			t.Fatalf("generate while b is not serving: %v", err)
+		}
+		if name == "a" {
+			run++
+		} else {
+			run = 0
+		}
+	}
+
+	b.health.SetServingStatus(config.RPCHealthService, healthpb.HealthCheckResponse_SERVING)
+	waitServed(t, "a", "b")
+}
@@ -0,0 +1,166 @@
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/rpc/CSTIRPC"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// backend is a fake rpc server responding its name as the generate result
+type backend struct {
+	CSTIRPC.UnimplementedCSTIRpcServerServer
+	name   string
+	addr   string
+	server *grpc.Server
+	health *health.Server
+}
+
+func (b *backend) JsonGenerate(ctx context.Context, req *CSTIRPC.ConfJson) (*CSTIRPC.ResultIcal, error) {
+	return &CSTIRPC.ResultIcal{Content: b.name}, nil
+}
+
+// startBackend serve a backend named name on a random port of 127.0.0.1, stopped when the test end.
+// The backend implements the health service, serving by default
+func startBackend(t *testing.T, name string, opts ...grpc.ServerOption) *backend {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &backend{name: name, addr: lis.Addr().String(), server: grpc.NewServer(opts...), health: health.NewServer()}
+	CSTIRPC.RegisterCSTIRpcServerServer(b.server, b)
+	healthpb.RegisterHealthServer(b.server, b.health)
+	go b.server.Serve(lis)
+	t.Cleanup(b.server.Stop)
+	return b
+}
+
+// initClient set the rpc options of config and Init the client with target,
+// the options are reset and the client closed when the test end
+func initClient(t *testing.T, target string) {
+	t.Helper()
+	config.RPCTarget = target
+	config.RPCTimeout = 5
+	config.RPCMaxRetries = 2
+	config.RPCBreakerThreshold = 0
+	t.Cleanup(func() {
+		Close()
+		config.RPCTLSCA, config.RPCTLSCert, config.RPCTLSKey, config.RPCTLSServerName = "", "", "", ""
+	})
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// generate call JSONGenerate with a deadline and return the name of backend served it
+func generate(t *testing.T) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return JSONGenerate(ctx, "{}")
+}
+
+// testCA is a certificate authority issuing the certificates of tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+// newTestCA generate a CA and write its certificate to ca.pem in a temporary directory
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "csti test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	writePEM(t, ca.path("ca.pem"), "CERTIFICATE", der)
+	return ca
+}
+
+func (ca *testCA) path(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+// issue a certificate for the IP addresses and DNS names, written to <name>.pem and <name>-key.pem
+func (ca *testCA) issue(t *testing.T, name string, ips []net.IP, dnsNames ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  ips,
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, ca.path(name+".pem"), "CERTIFICATE", der)
+	writePEM(t, ca.path(name+"-key.pem"), "EC PRIVATE KEY", keyDER)
+
+	cert, err := tls.LoadX509KeyPair(ca.path(name+".pem"), ca.path(name+"-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func writePEM(t *testing.T, path string, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// transportOption return the security option of rpc channel, TLS is used if any tls option
+// is configured, and mutual TLS if the client certificate is configured. Otherwise plaintext.
+func transportOption() (grpc.DialOption, error) {
+	if config.RPCTLSCA == "" && config.RPCTLSCert == "" && config.RPCTLSServerName == "" {
+		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: config.RPCTLSServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config.RPCTLSCA != "" {
+		pem, err := ioutil.ReadFile(config.RPCTLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New(fmt.Sprintf("no certificate found in %s", config.RPCTLSCA))
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.RPCTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.RPCTLSCert, config.RPCTLSKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), nil
+}
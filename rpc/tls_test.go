@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var localhost = []net.IP{net.ParseIP("127.0.0.1")}
+
+// TestTLSPool check the certificate of each backend in the pool is verified against its own host,
+// not the name of dial target, when rpc-tls-server-name is not set
+func TestTLSPool(t *testing.T) {
+	ca := newTestCA(t)
+	cert := ca.issue(t, "server", localhost)
+	creds := credentials.NewServerTLSFromCert(&cert)
+	a := startBackend(t, "a", grpc.Creds(creds))
+	b := startBackend(t, "b", grpc.Creds(creds))
+
+	config.RPCTLSCA = ca.path("ca.pem")
+	initClient(t, a.addr+","+b.addr)
+
+	served := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		name, err := generate(t)
+		if err != nil {
+			t.Fatalf("generate over TLS: %v", err)
+		}
+		served[name] = true
+	}
+	if !served["a"] || !served["b"] {
+		t.Errorf("expect both backends serving, got %v", served)
+	}
+}
+
+// TestTLSServerName check rpc-tls-server-name override the host of backends
+func TestTLSServerName(t *testing.T) {
+	ca := newTestCA(t)
+	cert := ca.issue(t, "server", nil, "rpc.example.com")
+	creds := credentials.NewServerTLSFromCert(&cert)
+	a := startBackend(t, "a", grpc.Creds(creds))
+	b := startBackend(t, "b", grpc.Creds(creds))
+
+	config.RPCTLSCA = ca.path("ca.pem")
+	config.RPCTLSServerName = "rpc.example.com"
+	initClient(t, a.addr+","+b.addr)
+
+	if _, err := generate(t); err != nil {
+		t.Fatalf("generate over TLS: %v", err)
+	}
+}
+
+// TestMutualTLS check the client certificate is presented and the untrusted server is refused
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, "server", localhost)},
+		ClientCAs:    ca.pool(),
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	a := startBackend(t, "a", grpc.Creds(creds))
+
+	config.RPCTLSCA = ca.path("ca.pem")
+	config.RPCTLSCert = ca.path("client.pem")
+	config.RPCTLSKey = ca.path("client-key.pem")
+	ca.issue(t, "client", nil)
+	initClient(t, a.addr)
+
+	if _, err := generate(t); err != nil {
+		t.Fatalf("generate over mutual TLS: %v", err)
+	}
+
+	// a server issued by another CA
+	other := newTestCA(t)
+	otherCert := other.issue(t, "server", localhost)
+	untrusted := startBackend(t, "c", grpc.Creds(credentials.NewServerTLSFromCert(&otherCert)))
+	Close()
+	initClient(t, untrusted.addr)
+	if _, err := generate(t); err == nil {
+		t.Fatal("expect the server of untrusted CA refused")
+	}
+}
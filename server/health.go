@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/db"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/rpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// probeTimeout bound the time of checking each dependency
+const probeTimeout = 2 * time.Second
+
+// dependency is checked by the probes, check return nil if it is usable
+type dependency struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+var dependencies = []dependency{
+	{name: "database", check: checkDatabase},
+	{name: "rpc", check: checkRPC},
+}
+
+func checkDatabase(ctx context.Context) error {
+	if db.DB == nil {
+		return errors.New("not connected")
+	}
+	return db.DB.PingContext(ctx)
+}
+
+// the connection is re-established by grpc in background, just report its state
+func checkRPC(ctx context.Context) error {
+	if state := rpc.State(); state != connectivity.Ready {
+		return fmt.Errorf("connection is %s", state)
+	}
+	return nil
+}
+
+// healthz respond 200 as long as the process is serving, the dependencies are not checked,
+// so the process won't be restarted for others' failure, nor blocked by a slow dependency
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, dto.NewResponseFine("ok"))
+}
+
+// readyz respond 503 if any dependency is not usable, so no request is routed to this process
+func readyz(c *gin.Context) {
+	statuses, ok := checkDependencies(c.Request.Context())
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, dto.NewResponseBad(statuses))
+		return
+	}
+	c.JSON(http.StatusOK, dto.NewResponseFine(statuses))
+}
+
+// checkDependencies return the status of each dependency, and true if all are usable
+func checkDependencies(ctx context.Context) (map[string]dto.DependencyStatus, bool) {
+	statuses := make(map[string]dto.DependencyStatus, len(dependencies))
+	allOK := true
+	for _, d := range dependencies {
+		checkCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		start := time.Now()
+		err := d.check(checkCtx)
+		cancel()
+
+		status := dto.DependencyStatus{Status: "ok", LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+		if err != nil {
+			status.Status = "bad"
+			status.Error = err.Error()
+			allOK = false
+		}
+		statuses[d.name] = status
+	}
+	return statuses, allOK
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthz never checks the dependencies, while readyz fails with any of them
+func TestProbes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	checked := 0
+	old := dependencies
+	dependencies = []dependency{{name: "database", check: func(ctx context.Context) error {
+		checked++
+		return errors.New("connection refused")
+	}}}
+	defer func() { dependencies = old }()
+
+	engine := gin.New()
+	engine.GET("/healthz", healthz)
+	engine.GET("/readyz", readyz)
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := serve("/healthz"); code != http.StatusOK || checked != 0 {
+		t.Errorf("healthz: status %d, dependencies checked %d times", code, checked)
+	}
+	if code := serve("/readyz"); code != http.StatusServiceUnavailable || checked != 1 {
+		t.Errorf("readyz: status %d, dependencies checked %d times", code, checked)
+	}
+}
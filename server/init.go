@@ -1,19 +1,109 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/metrics"
+	"github.com/sirupsen/logrus"
 )
 
 // Engine is gin Engine
 var Engine *gin.Engine
 
-// Init create the gin engine
+// Init create the gin engine, the access log is written by middlewares in json.
+// Health probes, and metrics if metrics-endpoint is not set, are registered before
+// middlewares so probing and scraping are neither authenticated nor logged.
 func Init() {
-	Engine = gin.Default()
+	Engine = gin.New()
+	Engine.Use(gin.Recovery())
+	if config.HealthPath != "" {
+		Engine.GET(config.HealthPath, healthz)
+	}
+	if config.ReadyPath != "" {
+		Engine.GET(config.ReadyPath, readyz)
+	}
+	if config.MetricsEndpoint == "" && config.MetricsPath != "" {
+		Engine.GET(config.MetricsPath, gin.WrapH(metrics.Handler()))
+	}
+}
+
+// Run start listen and handle http request, call this func at last.
+// It return after SIGTERM or SIGINT received and the in-flight requests finished,
+// or shutdown-timeout passed. The caller should release the resources then.
+func Run() error {
+	srv := &http.Server{
+		Addr:         config.RestEndpoint,
+		Handler:      Engine,
+		ReadTimeout:  time.Duration(config.HTTPReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(config.HTTPWriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(config.HTTPIdleTimeout) * time.Second,
+	}
+
+	// both rest server and metrics server may fail
+	listenErr := make(chan error, 2)
+	if config.HTTPSCert != "" {
+		reloader, err := newCertReloader(config.HTTPSCert, config.HTTPSKey)
+		if err != nil {
+			return err
+		}
+		defer reloader.stop()
+		srv.TLSConfig = &tls.Config{GetCertificate: reloader.getCertificate, MinVersion: tls.VersionTLS12}
+
+		logrus.Infof("listening https on %s", config.RestEndpoint)
+		go func() { listenErr <- srv.ListenAndServeTLS("", "") }()
+	} else {
+		logrus.Infof("listening http on %s", config.RestEndpoint)
+		go func() { listenErr <- srv.ListenAndServe() }()
+	}
+
+	if config.MetricsEndpoint != "" {
+		metricsSrv := newMetricsServer()
+		logrus.Infof("serving metrics on %s%s", config.MetricsEndpoint, config.MetricsPath)
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != http.ErrServerClosed {
+				listenErr <- err
+			}
+		}()
+		defer metricsSrv.Close()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(quit)
+
+	select {
+	case err := <-listenErr:
+		return err
+	case sig := <-quit:
+		logrus.Infof("received %s, waiting the in-flight requests", sig)
+	}
+
+	ctx := context.Background()
+	if config.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(config.ShutdownTimeout)*time.Second)
+		defer cancel()
+	}
+	return srv.Shutdown(ctx)
 }
 
-// Run start listen and handle http request, call this func at last
-func Run() {
-	Engine.Run(config.RestEndpoint)
+// the metrics server listen on metrics-endpoint, usually a private address
+func newMetricsServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(config.MetricsPath, metrics.Handler())
+	return &http.Server{
+		Addr:         config.MetricsEndpoint,
+		Handler:      mux,
+		ReadTimeout:  time.Duration(config.HTTPReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(config.HTTPWriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(config.HTTPIdleTimeout) * time.Second,
+	}
 }
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+)
+
+// freeEndpoint return a local address not listened by others
+func freeEndpoint(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// TestRunGracefulShutdown check the in-flight request is finished after SIGTERM before Run return
+func TestRunGracefulShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.RestEndpoint = freeEndpoint(t)
+	config.ShutdownTimeout = 5
+	config.MetricsEndpoint = ""
+	config.HTTPSCert = ""
+
+	started := make(chan struct{})
+	Engine = gin.New()
+	Engine.GET("/slow", func(c *gin.Context) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+
+	ran := make(chan error, 1)
+	go func() { ran <- Run() }()
+
+	url := "http://" + config.RestEndpoint + "/slow"
+	type result struct {
+		body string
+		err  error
+	}
+	responded := make(chan result, 1)
+	go func() {
+		// Run may not be listening yet
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			res, err := http.Get(url)
+			if err != nil && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			if err != nil {
+				responded <- result{err: err}
+				return
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			responded <- result{body: string(body), err: err}
+			return
+		}
+	}()
+
+	select {
+	case <-started:
+	case err := <-ran:
+		t.Fatalf("Run returned before serving: %v", err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("request not started")
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := <-responded; res.err != nil || res.body != "done" {
+		t.Errorf("expect the in-flight request finished, got %q, %v", res.body, res.err)
+	}
+	select {
+	case err := <-ran:
+		if err != nil {
+			t.Errorf("expect Run return nil after shutdown, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run not returned after SIGTERM")
+	}
+
+	// no new connection is accepted after shutdown
+	if _, err := http.Get(url); err == nil {
+		t.Error("expect connection refused after shutdown")
+	}
+}
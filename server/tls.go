@@ -0,0 +1,66 @@
+package server
+
+import (
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// certReloader serve the certificate loaded from files, and reload them on SIGHUP
+// so a renewed certificate is used without restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+
+	hup chan os.Signal
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, hup: make(chan os.Signal, 1)}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	signal.Notify(r.hup, syscall.SIGHUP)
+	go func() {
+		for range r.hup {
+			if err := r.reload(); err != nil {
+				// keep serving the old certificate
+				logrus.Errorf("failed to reload certificate: %v", err)
+			} else {
+				logrus.Info("certificate reloaded")
+			}
+		}
+	}()
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// stop watching SIGHUP
+func (r *certReloader) stop() {
+	signal.Stop(r.hup)
+	close(r.hup)
+}
@@ -0,0 +1,84 @@
+package sqlstore
+
+import (
+	"fmt"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+)
+
+type configStore struct {
+	conn
+}
+
+func (s *configStore) Create(req dto.ConfigCreateReq, ownerID int64) (int64, error) {
+	return s.insert(
+		"insert into t_config (c_type, c_name, c_content, c_format, c_owner_id, c_remark)"+
+			" values (?, ?, ?, ?, ?, ?)",
+		req.Type, req.Name, req.Content, req.Format, ownerID, req.Remark)
+}
+
+func (s *configStore) Get(configID int64) (dto.ConfigGetRes, int64, error) {
+	var res dto.ConfigGetRes
+	var ownerID int64
+	row := s.db.QueryRow(
+		"select c_id, c_type, c_name, c_content, c_format, c_remark, c_create_time, c_modify_time, c_owner_id "+
+			"from t_config where c_deleted = false and c_id = ?", configID)
+	err := row.Scan(&res.ID, &res.Type, &res.Name, &res.Content, &res.Format,
+		&res.Remark, &res.CreateTime, &res.ModifyTime, &ownerID)
+	return res, ownerID, notFound(err)
+}
+
+func (s *configStore) GetByShare(configShareID int64) (dto.ConfigGetRes, error) {
+	var res dto.ConfigGetRes
+	row := s.db.QueryRow(
+		"select c_id, c_type, c_name, c_content, c_format, c_remark, c_create_time, c_modify_time "+
+			"from t_config where c_deleted = false and c_id = "+
+			"(select c_config_id from t_config_share where c_deleted = false and c_id = ?);", configShareID)
+	err := row.Scan(&res.ID, &res.Type, &res.Name, &res.Content, &res.Format,
+		&res.Remark, &res.CreateTime, &res.ModifyTime)
+	return res, notFound(err)
+}
+
+func (s *configStore) Owner(configID int64) (int64, int8, error) {
+	var ownerID int64
+	var configType int8
+	row := s.db.QueryRow("select c_owner_id, c_type from t_config where c_deleted = false and c_id = ?", configID)
+	err := row.Scan(&ownerID, &configType)
+	return ownerID, configType, notFound(err)
+}
+
+func (s *configStore) Modify(req dto.ConfigModifyReq) error {
+	_, err := s.db.Exec("update t_config"+
+		" set c_name=?, c_content=?, c_format=?, c_remark=?, c_modify_time="+s.dialect.now+
+		" where c_id = ?",
+		req.Name, req.Content, req.Format, req.Remark, req.ID)
+	return err
+}
+
+// Remove also update the modify time of plans using the config, since the config
+// no longer counts in their last modified time
+func (s *configStore) Remove(configID int64) (bool, error) {
+	var removed bool
+	err := withTx(s.db, func(tx rebindTx) error {
+		var err error
+		if removed, err = affected(tx.Exec("update t_config set c_deleted = true, c_modify_time = "+s.dialect.now+
+			" where c_id = ? and c_deleted = false;", configID)); err != nil || !removed {
+			return err
+		}
+		_, err = tx.Exec("update t_plan set c_modify_time = "+s.dialect.now+" where c_id in ("+
+			"select c_plan_id from t_plan_config_relation where c_config_id = ?"+
+			" union select r.c_plan_id from t_plan_config_share_relation r"+
+			" join t_config_share s on s.c_id = r.c_config_share_id where s.c_config_id = ?);",
+			configID, configID)
+		return err
+	})
+	return removed, err
+}
+
+func (s *configStore) List(ownerID int64, sortBy string, offset int64, count int64) ([]dto.ConfigSummary, error) {
+	const sqlCommandPre = "select c_id, c_type, c_name, c_format, c_remark, c_create_time, c_modify_time from t_config" +
+		" where c_owner_id = ? and c_deleted = false order by %s limit ? offset ?;"
+	var configs []dto.ConfigSummary = make([]dto.ConfigSummary, 0)
+	err := s.db.Select(&configs, fmt.Sprintf(sqlCommandPre, sortColumn(sortBy)), ownerID, count, offset)
+	return configs, err
+}
@@ -0,0 +1,90 @@
+package sqlstore
+
+import (
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// dialect contains the SQL differs between database backends
+type dialect struct {
+	// now is the expression of current time
+	now string
+
+	// later return the expression of current time plus amount of unit,
+	// amount is an expression and unit is "day" or "hour"
+	later func(amount string, unit string) string
+
+	// forUpdate is appended to select to lock the selected rows until the transaction end
+	forUpdate string
+
+	// isDuplicate return true if err is caused by violating unique constraint
+	isDuplicate func(err error) bool
+
+	// returning is true if the driver can't report the last insert id,
+	// the id is read by appending "returning c_id" to insert instead
+	returning bool
+
+	// system is the name of backend in traces, see db.system of OpenTelemetry semantic conventions
+	system string
+}
+
+var mysqlDialect = dialect{
+	now: "now()",
+	later: func(amount string, unit string) string {
+		return fmt.Sprintf("now() + interval (%s) %s", amount, unit)
+	},
+	forUpdate: " for update",
+	isDuplicate: func(err error) bool {
+		mysqlErr, ok := err.(*mysql.MySQLError)
+		// ER_DUP_ENTRY
+		return ok && mysqlErr.Number == 1062
+	},
+	system: "mysql",
+}
+
+// time is stored as text in UTC, so it must be written by datetime() to be comparable
+var sqliteDialect = dialect{
+	now: "datetime('now')",
+	later: func(amount string, unit string) string {
+		return fmt.Sprintf("datetime('now', '+' || (%s) || ' %ss')", amount, unit)
+	},
+	// the whole database is locked by transaction begin immediately
+	forUpdate: "",
+	isDuplicate: func(err error) bool {
+		sqliteErr, ok := err.(sqlite3.Error)
+		return ok && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+	},
+	system: "sqlite",
+}
+
+// time is stored as timestamp without time zone in UTC, the session time zone
+// is set to UTC by the connection
+var postgresDialect = dialect{
+	now: "now()",
+	later: func(amount string, unit string) string {
+		return fmt.Sprintf("now() + make_interval(%ss => (%s))", unit, amount)
+	},
+	forUpdate: " for update",
+	isDuplicate: func(err error) bool {
+		pqErr, ok := err.(*pq.Error)
+		// unique_violation
+		return ok && pqErr.Code == "23505"
+	},
+	returning: true,
+	system:    "postgresql",
+}
+
+func dialectOf(driver string) *dialect {
+	switch driver {
+	case "sqlite3":
+		return &sqliteDialect
+	case "postgres":
+		return &postgresDialect
+	default:
+		return &mysqlDialect
+	}
+}
@@ -0,0 +1,123 @@
+package sqlstore
+
+import (
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+)
+
+type favorStore struct {
+	conn
+}
+
+func (s *favorStore) AddConfig(userID int64, configShareID int64) error {
+	err := withTx(s.db, func(tx rebindTx) error {
+		var cnt int64
+		err := tx.Get(&cnt, "select count(*) from t_user_favourite_config where c_config_share_id = ? and c_user_id = ?;",
+			configShareID, userID)
+		if err != nil {
+			return err
+		}
+		if cnt > 0 {
+			return store.ErrDuplicate
+		}
+		_, err = tx.Exec("insert into t_user_favourite_config (c_user_id, c_config_share_id) values (?, ?);",
+			userID, configShareID)
+		return err
+	})
+	if s.dialect.isDuplicate(err) {
+		return store.ErrDuplicate
+	}
+	return err
+}
+
+func (s *favorStore) RemoveConfig(userID int64, configShareID int64) error {
+	_, err := s.db.Exec("delete from t_user_favourite_config where c_user_id = ? and c_config_share_id = ?;",
+		userID, configShareID)
+	return err
+}
+
+func (s *favorStore) ListConfigs(userID int64, offset int64, count int64) ([]dto.FavorConfigSummary, error) {
+	const sqlCommand string = `
+		select
+			tcs.c_id, tufc.c_create_time, tc.c_name, tc.c_remark, tc.c_type, tc.c_format, tc.c_create_time, tc.c_modify_time
+		from t_config as tc
+			join t_config_share as tcs on tc.c_id = tcs.c_config_id
+			join t_user_favourite_config as tufc on tcs.c_id = tufc.c_config_share_id
+		where tc.c_deleted = false
+			and tcs.c_deleted = false
+			and tufc.c_user_id = ?
+		limit ? offset ?`
+
+	rows, err := s.db.Query(sqlCommand, userID, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []dto.FavorConfigSummary = make([]dto.FavorConfigSummary, 0)
+	for rows.Next() {
+		var c dto.FavorConfigSummary
+		err = rows.Scan(&c.ShareID, &c.FavorTime, &c.Name, &c.Remark, &c.Type, &c.Format, &c.CreateTime, &c.ModifyTime)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, c)
+	}
+	return configs, rows.Err()
+}
+
+func (s *favorStore) AddPlan(userID int64, planShareID int64) error {
+	err := withTx(s.db, func(tx rebindTx) error {
+		var cnt int64
+		err := tx.Get(&cnt, "select count(*) from t_user_favourite_plan where c_plan_share_id = ? and c_user_id = ?;",
+			planShareID, userID)
+		if err != nil {
+			return err
+		}
+		if cnt > 0 {
+			return store.ErrDuplicate
+		}
+		_, err = tx.Exec("insert into t_user_favourite_plan (c_user_id, c_plan_share_id) values (?, ?);",
+			userID, planShareID)
+		return err
+	})
+	if s.dialect.isDuplicate(err) {
+		return store.ErrDuplicate
+	}
+	return err
+}
+
+func (s *favorStore) RemovePlan(userID int64, planShareID int64) error {
+	_, err := s.db.Exec("delete from t_user_favourite_plan where c_user_id = ? and c_plan_share_id = ?;",
+		userID, planShareID)
+	return err
+}
+
+func (s *favorStore) ListPlans(userID int64, offset int64, count int64) ([]dto.FavorPlanSummary, error) {
+	const sqlCommand = `
+		select tps.c_id, tufp.c_create_time, tp.c_name, tp.c_remark, tp.c_create_time, tp.c_modify_time
+		from t_plan as tp
+			join t_plan_share as tps on tp.c_id = tps.c_plan_id
+			join t_user_favourite_plan as tufp on tps.c_id = tufp.c_plan_share_id
+		where tp.c_deleted = false
+			and tps.c_deleted = false
+			and tufp.c_user_id = ?
+		limit ? offset ?;`
+
+	rows, err := s.db.Query(sqlCommand, userID, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plans []dto.FavorPlanSummary = make([]dto.FavorPlanSummary, 0)
+	for rows.Next() {
+		var p dto.FavorPlanSummary
+		err = rows.Scan(&p.ShareID, &p.FavorTime, &p.Name, &p.Remark, &p.CreateTime, &p.ModifyTime)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
@@ -0,0 +1,306 @@
+package sqlstore
+
+import (
+	"fmt"
+	"time"
+
+	confcontent "github.com/leafee98/class-schedule-to-icalendar-restserver/content"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+)
+
+type planStore struct {
+	conn
+}
+
+func (s *planStore) Create(req dto.PlanCreateReq, ownerID int64) (int64, error) {
+	return s.insert("insert into t_plan (c_name, c_owner_id, c_remark) values (?, ?, ?);",
+		req.Name, ownerID, req.Remark)
+}
+
+func (s *planStore) Get(planID int64) (dto.PlanGetRes, error) {
+	var plan dto.PlanGetRes
+	plan.Configs = make([]dto.ConfigDetail, 0)
+	plan.Shares = make([]dto.ConfigDetail, 0)
+
+	const sqlCommandGetPlan string = "select c_id, c_name, c_remark, c_create_time, c_modify_time, c_generate_count " +
+		"from t_plan where c_deleted = false and c_id = ?;"
+	row := s.db.QueryRow(sqlCommandGetPlan, planID)
+	if err := row.Scan(&plan.ID, &plan.Name, &plan.Remark, &plan.CreateTime, &plan.ModifyTime,
+		&plan.GenerateCount); err != nil {
+		return plan, notFound(err)
+	}
+
+	const sqlCommandGetConfigs = "select " +
+		"c_id, c_type, c_name, c_content, c_format, c_remark, c_create_time, c_modify_time " +
+		"from t_config where c_deleted = false and c_id in " +
+		"(select c_config_id from t_plan_config_relation where c_plan_id = ?);"
+	if err := s.db.Select(&plan.Configs, sqlCommandGetConfigs, planID); err != nil {
+		return plan, err
+	}
+
+	const sqlCommandGetShares = "" +
+		"select s.c_id, c.c_type, c.c_name, c.c_content, c.c_format, c.c_remark, c.c_create_time, c.c_modify_time " +
+		"from " +
+		"	t_config as c " +
+		"	join t_config_share as s " +
+		"	on c.c_id = s.c_config_id " +
+		"where " +
+		"	c.c_deleted = false " +
+		"	and s.c_deleted = false " +
+		"	and	s.c_id in ( " +
+		"		select c_config_share_id " +
+		"		from t_plan_config_share_relation " +
+		"		where c_plan_id = ? " +
+		"	);"
+	err := s.db.Select(&plan.Shares, sqlCommandGetShares, planID)
+	return plan, err
+}
+
+func (s *planStore) Owner(planID int64) (int64, error) {
+	var ownerID int64
+	row := s.db.QueryRow("select c_owner_id from t_plan where c_id = ? and c_deleted = false", planID)
+	err := row.Scan(&ownerID)
+	return ownerID, notFound(err)
+}
+
+func (s *planStore) Modify(req dto.PlanModifyReq) error {
+	_, err := s.db.Exec("update t_plan set c_name = ?, c_remark = ?, c_modify_time = "+s.dialect.now+
+		" where c_id = ?;", req.Name, req.Remark, req.ID)
+	return err
+}
+
+func (s *planStore) Remove(planID int64) (bool, error) {
+	return affected(s.db.Exec("update t_plan set c_deleted = true, c_modify_time = "+s.dialect.now+
+		" where c_id = ?;", planID))
+}
+
+func (s *planStore) List(ownerID int64, sortBy string, offset int64, count int64) ([]dto.PlanSummary, error) {
+	const sqlCommandPre = "select c_id, c_name, c_remark, c_create_time, c_modify_time" +
+		" from t_plan where c_owner_id = ? and c_deleted = false order by %s limit ? offset ?;"
+	var plans []dto.PlanSummary = make([]dto.PlanSummary, 0)
+	err := s.db.Select(&plans, fmt.Sprintf(sqlCommandPre, sortColumn(sortBy)), ownerID, count, offset)
+	return plans, err
+}
+
+func (s *planStore) CountGeneration(planID int64) error {
+	_, err := s.db.Exec("update t_plan set c_generate_count = c_generate_count + 1 where c_id = ?;", planID)
+	return err
+}
+
+func (s *planStore) ModifyTime(planID int64) (time.Time, error) {
+	var modifyTime time.Time
+	err := s.db.Get(&modifyTime, "select c_modify_time from t_plan where c_id = ?;", planID)
+	return modifyTime, notFound(err)
+}
+
+func (s *planStore) Configs(planID int64) ([]confcontent.Config, error) {
+	const sqlGetConfig string = `
+		select c_id, c_content, c_type, c_format, c_modify_time, false as c_shared
+		from t_config
+		where c_deleted = false
+			and c_id in (
+				select c_config_id
+				from t_plan_config_relation
+				where c_plan_id = ?
+			)
+		union all
+		select c_id, c_content, c_type, c_format, c_modify_time, true as c_shared
+		from t_config
+		where c_deleted = false
+			and c_id in (
+				select c_config_id
+				from t_config_share
+				where c_deleted = false
+					and c_id in (
+						select c_config_share_id
+						from t_plan_config_share_relation
+						where c_plan_id = ?
+					)
+			);`
+
+	rows, err := s.db.Query(sqlGetConfig, planID, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []confcontent.Config = make([]confcontent.Config, 0)
+	for rows.Next() {
+		var conf confcontent.Config
+		if err := rows.Scan(&conf.ID, &conf.Content, &conf.Type, &conf.Format, &conf.ModifyTime, &conf.Shared); err != nil {
+			return nil, err
+		}
+		configs = append(configs, conf)
+	}
+	return configs, rows.Err()
+}
+
+func (s *planStore) IDsByConfig(configID int64) ([]int64, error) {
+	const sqlCommand string = `
+		select c_plan_id from t_plan_config_relation where c_config_id = ?
+		union
+		select c_plan_id from t_plan_config_share_relation where c_config_share_id in (
+			select c_id from t_config_share where c_config_id = ?);`
+	var planIDs []int64
+	err := s.db.Select(&planIDs, sqlCommand, configID, configID)
+	return planIDs, err
+}
+
+func (s *planStore) IDsByConfigShare(configShareID int64) ([]int64, error) {
+	const sqlCommand string = "select c_plan_id from t_plan_config_share_relation where c_config_share_id = ?;"
+	var planIDs []int64
+	err := s.db.Select(&planIDs, sqlCommand, configShareID)
+	return planIDs, err
+}
+
+func (s *planStore) ConfigExist(planID int64, configID int64) (bool, error) {
+	return relationExist(s.db, planID, configID)
+}
+
+func (s *planStore) AddConfig(planID int64, configID int64) error {
+	err := withTx(s.db, func(tx rebindTx) error {
+		if err := s.lockPlan(tx, planID); err != nil {
+			return err
+		}
+		if exist, err := relationExist(tx, planID, configID); err != nil {
+			return err
+		} else if exist {
+			return store.ErrDuplicate
+		}
+		if _, err := tx.Exec("insert into t_plan_config_relation (c_plan_id, c_config_id) values (?, ?)",
+			planID, configID); err != nil {
+			return err
+		}
+		return s.touchPlan(tx, planID)
+	})
+	if s.dialect.isDuplicate(err) {
+		return store.ErrDuplicate
+	}
+	return err
+}
+
+func (s *planStore) RemoveConfig(planID int64, configID int64) (bool, error) {
+	const sqlCommand string = `delete from t_plan_config_relation where c_plan_id = ? and c_config_id = ?;`
+	return s.removeRelation(sqlCommand, planID, configID)
+}
+
+func (s *planStore) ConfigShareExist(planID int64, configShareID int64) (bool, error) {
+	return relationShareExist(s.db, planID, configShareID)
+}
+
+func (s *planStore) AddConfigShare(planID int64, configShareID int64) error {
+	err := withTx(s.db, func(tx rebindTx) error {
+		if err := s.lockPlan(tx, planID); err != nil {
+			return err
+		}
+		if exist, err := relationShareExist(tx, planID, configShareID); err != nil {
+			return err
+		} else if exist {
+			return store.ErrDuplicate
+		}
+		if _, err := tx.Exec("insert into t_plan_config_share_relation (c_plan_id, c_config_share_id) values (?, ?);",
+			planID, configShareID); err != nil {
+			return err
+		}
+		return s.touchPlan(tx, planID)
+	})
+	if s.dialect.isDuplicate(err) {
+		return store.ErrDuplicate
+	}
+	return err
+}
+
+func (s *planStore) RemoveConfigShare(planID int64, configShareID int64) (bool, error) {
+	const sqlCommand string = `delete from t_plan_config_share_relation where c_plan_id = ? and c_config_share_id = ?;`
+	return s.removeRelation(sqlCommand, planID, configShareID)
+}
+
+func (s *planStore) CreateToken(planID int64, token string, max int64) error {
+	return withTx(s.db, func(tx rebindTx) error {
+		if err := s.lockPlan(tx, planID); err != nil {
+			return err
+		}
+		var tokenCount int64
+		if err := tx.Get(&tokenCount, "select count(*) from t_plan_token where c_plan_id = ?;", planID); err != nil {
+			return err
+		}
+		if tokenCount >= max {
+			return store.ErrLimitExceeded
+		}
+		_, err := tx.Exec("insert into t_plan_token (c_plan_id, c_token) values (?, ?)", planID, token)
+		return err
+	})
+}
+
+func (s *planStore) TokenPlan(token string) (int64, error) {
+	const sqlGetPlanId string = `
+		select c_id from t_plan where c_deleted = false and c_id = (
+			select c_plan_id from t_plan_token where c_token = ?);`
+	var planID int64
+	err := s.db.Get(&planID, sqlGetPlanId, token)
+	return planID, notFound(err)
+}
+
+func (s *planStore) RevokeToken(token string) (bool, error) {
+	return affected(s.db.Exec("delete from t_plan_token where c_token = ?;", token))
+}
+
+func (s *planStore) ListTokens(planID int64) ([]dto.PlanTokenDetail, error) {
+	rows, err := s.db.Query("select c_token, c_create_time from t_plan_token where c_plan_id = ?;", planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []dto.PlanTokenDetail = make([]dto.PlanTokenDetail, 0)
+	for rows.Next() {
+		var token dto.PlanTokenDetail
+		if err := rows.Scan(&token.Token, &token.CreateTime); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// delete the relation of plan by sqlCommand, and update the modify time of plan if deleted
+func (s *planStore) removeRelation(sqlCommand string, planID int64, relatedID int64) (bool, error) {
+	var removed bool
+	err := withTx(s.db, func(tx rebindTx) error {
+		var err error
+		if removed, err = affected(tx.Exec(sqlCommand, planID, relatedID)); err != nil || !removed {
+			return err
+		}
+		return s.touchPlan(tx, planID)
+	})
+	return removed, err
+}
+
+// update the modify time of plan when its relations changed
+func (s *planStore) touchPlan(tx rebindTx, planID int64) error {
+	_, err := tx.Exec("update t_plan set c_modify_time = "+s.dialect.now+" where c_id = ?", planID)
+	return err
+}
+
+// lock the plan until the transaction end,
+// so the check-then-insert on the same plan won't run concurrently
+func (s *planStore) lockPlan(tx rebindTx, planID int64) error {
+	var id int64
+	err := tx.Get(&id, "select c_id from t_plan where c_id = ?"+s.dialect.forUpdate, planID)
+	return notFound(err)
+}
+
+func relationExist(q getter, planID int64, configID int64) (bool, error) {
+	var cnt int64
+	err := q.Get(&cnt, "select count(*) as cnt from t_plan_config_relation"+
+		" where c_plan_id = ? and c_config_id = ?", planID, configID)
+	return cnt > 0, err
+}
+
+func relationShareExist(q getter, planID int64, configShareID int64) (bool, error) {
+	var cnt int64
+	err := q.Get(&cnt, "select count(*) as cnt from t_plan_config_share_relation "+
+		"where c_plan_id = ? and c_config_share_id = ?", planID, configShareID)
+	return cnt > 0, err
+}
@@ -0,0 +1,83 @@
+package sqlstore
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+)
+
+func TestConcurrentAdds(t *testing.T) {
+	_, st := openTestDB(t)
+	testConcurrentAdds(t, st)
+}
+
+// the check-then-insert of relations, favors and plan tokens stay consistent when run concurrently
+func testConcurrentAdds(t *testing.T, st *store.Store) {
+	userID, err := st.Users.Create("concurrent", []byte("hash"), "concurrent@example.com", "concurrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configID, err := st.Configs.Create(dto.ConfigCreateReq{
+		Name: "semester", Type: 1, Content: "{}", Format: 1, Remark: "global"}, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shareID, err := st.Shares.CreateConfigShare(configID, "share")
+	if err != nil {
+		t.Fatal(err)
+	}
+	planID, err := st.Plans.Create(dto.PlanCreateReq{Name: "plan", Remark: "plan"}, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run f concurrently, and count the errors returned
+	race := func(f func(i int) error) map[error]int {
+		const n = 8
+		errs := make(chan error, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- f(i)
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		count := make(map[error]int)
+		for err := range errs {
+			count[err]++
+		}
+		return count
+	}
+	check := func(name string, got map[error]int, want map[error]int) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: errors %v, want %v", name, got, want)
+		}
+	}
+
+	check("AddConfig", race(func(int) error {
+		return st.Plans.AddConfig(planID, configID)
+	}), map[error]int{nil: 1, store.ErrDuplicate: 7})
+	check("AddConfigShare", race(func(int) error {
+		return st.Plans.AddConfigShare(planID, shareID)
+	}), map[error]int{nil: 1, store.ErrDuplicate: 7})
+	check("Favors.AddConfig", race(func(int) error {
+		return st.Favors.AddConfig(userID, shareID)
+	}), map[error]int{nil: 1, store.ErrDuplicate: 7})
+	check("CreateToken", race(func(i int) error {
+		return st.Plans.CreateToken(planID, fmt.Sprintf("token-%d", i), 3)
+	}), map[error]int{nil: 3, store.ErrLimitExceeded: 5})
+
+	tokens, err := st.Plans.ListTokens(planID)
+	if err != nil || len(tokens) != 3 {
+		t.Errorf("ListTokens() = %d tokens, %v, want 3", len(tokens), err)
+	}
+}
@@ -0,0 +1,24 @@
+package sqlstore
+
+import (
+	"testing"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/db"
+)
+
+// TestPostgres run the store tests on postgres, the statements are rebound to $n placeholders,
+// the relations are locked by "for update" and the unique violations of pq are duplicates
+func TestPostgres(t *testing.T) {
+	conn, st := openPostgres(t)
+
+	var version int
+	if err := conn.Get(&version, "select max(c_version) from t_schema_version"); err != nil {
+		t.Fatal(err)
+	}
+	if latest := db.LatestVersion("postgres"); version != latest {
+		t.Errorf("schema version %d, want %d", version, latest)
+	}
+
+	t.Run("TouchSession", func(t *testing.T) { testTouchSession(t, conn, st) })
+	t.Run("ConcurrentAdds", func(t *testing.T) { testConcurrentAdds(t, st) })
+}
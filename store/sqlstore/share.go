@@ -0,0 +1,99 @@
+package sqlstore
+
+import (
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+)
+
+type shareStore struct {
+	conn
+}
+
+////// Config Share Part //////
+
+func (s *shareStore) CreateConfigShare(configID int64, remark string) (int64, error) {
+	return s.insert("insert into t_config_share (c_config_id, c_remark) values (?, ?);", configID, remark)
+}
+
+func (s *shareStore) ModifyConfigShare(configShareID int64, remark string) error {
+	_, err := s.db.Exec("update t_config_share set c_remark = ? where c_id = ?;", remark, configShareID)
+	return err
+}
+
+// RevokeConfigShare also update the modify time of plans using the config share,
+// since its config no longer counts in their last modified time
+func (s *shareStore) RevokeConfigShare(configShareID int64) error {
+	return withTx(s.db, func(tx rebindTx) error {
+		if _, err := tx.Exec("update t_config_share set c_deleted = true where c_id = ?;", configShareID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("update t_plan set c_modify_time = "+s.dialect.now+" where c_id in ("+
+			"select c_plan_id from t_plan_config_share_relation where c_config_share_id = ?);", configShareID)
+		return err
+	})
+}
+
+func (s *shareStore) ListConfigShares(configID int64) ([]dto.ConfigShareDetail, error) {
+	const sqlCommand = "select c_id, c_create_time, c_remark from t_config_share " +
+		"where c_deleted = false and c_config_id = ?;"
+	var shares []dto.ConfigShareDetail = make([]dto.ConfigShareDetail, 0)
+	err := s.db.Select(&shares, sqlCommand, configID)
+	return shares, err
+}
+
+func (s *shareStore) ConfigShareExist(configShareID int64) (bool, error) {
+	var count int64
+	err := s.db.Get(&count, "select count(*) from t_config_share where c_deleted = false and c_id = ?;", configShareID)
+	return count > 0, err
+}
+
+func (s *shareStore) ConfigShareOwner(configShareID int64) (int64, error) {
+	const sqlCommand string = "select c_owner_id from t_config where c_deleted = false and c_id = " +
+		"(select c_config_id from t_config_share where c_deleted = false and c_id = ?);"
+	var ownerID int64
+	err := s.db.Get(&ownerID, sqlCommand, configShareID)
+	return ownerID, notFound(err)
+}
+
+/////// Plan Share Part ///////
+
+func (s *shareStore) CreatePlanShare(planID int64, remark string) (int64, error) {
+	return s.insert("insert into t_plan_share (c_plan_id, c_remark) values (?, ?);", planID, remark)
+}
+
+func (s *shareStore) ModifyPlanShare(planShareID int64, remark string) error {
+	_, err := s.db.Exec("update t_plan_share set c_remark = ? where c_id = ?;", remark, planShareID)
+	return err
+}
+
+func (s *shareStore) RevokePlanShare(planShareID int64) error {
+	_, err := s.db.Exec("update t_plan_share set c_deleted = true where c_id = ?;", planShareID)
+	return err
+}
+
+func (s *shareStore) ListPlanShares(planID int64) ([]dto.PlanShareDetail, error) {
+	const sqlCommand = "select c_id, c_create_time, c_remark from t_plan_share " +
+		"where c_deleted = false and c_plan_id = ?;"
+	var shares []dto.PlanShareDetail = make([]dto.PlanShareDetail, 0)
+	err := s.db.Select(&shares, sqlCommand, planID)
+	return shares, err
+}
+
+func (s *shareStore) PlanShareExist(planShareID int64) (bool, error) {
+	var count int64
+	err := s.db.Get(&count, "select count(*) from t_plan_share where c_deleted = false and c_id = ?;", planShareID)
+	return count > 0, err
+}
+
+func (s *shareStore) PlanShareOwner(planShareID int64) (int64, error) {
+	const sqlCommand string = "select c_owner_id from t_plan where c_deleted = false and c_id = " +
+		"(select c_plan_id from t_plan_share where c_deleted = false and c_id = ?);"
+	var ownerID int64
+	err := s.db.Get(&ownerID, sqlCommand, planShareID)
+	return ownerID, notFound(err)
+}
+
+func (s *shareStore) PlanSharePlan(planShareID int64) (int64, error) {
+	var planID int64
+	err := s.db.Get(&planID, "select c_plan_id from t_plan_share where c_deleted = false and c_id = ?;", planShareID)
+	return planID, notFound(err)
+}
@@ -0,0 +1,192 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/metrics"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/tracing"
+	"github.com/sirupsen/logrus"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// conn is the database shared by stores and its dialect
+type conn struct {
+	db      rebindDB
+	dialect *dialect
+}
+
+// New create stores backed by the database, the SQL dialect is chosen by
+// the driver name of db, "mysql", "sqlite3" or "postgres"
+func New(db *sqlx.DB) *store.Store {
+	c := conn{db: rebindDB{DB: db, ctx: context.Background()}, dialect: dialectOf(db.DriverName())}
+	return c.stores()
+}
+
+// stores return the stores sharing c, WithContext of them return the stores
+// with a copy of c running statements with ctx
+func (c conn) stores() *store.Store {
+	return &store.Store{
+		Users:   &userStore{c},
+		Configs: &configStore{c},
+		Plans:   &planStore{c},
+		Shares:  &shareStore{c},
+		Favors:  &favorStore{c},
+		Tokens:  &tokenStore{c},
+		WithContext: func(ctx context.Context) *store.Store {
+			c.db.ctx = ctx
+			return c.stores()
+		},
+	}
+}
+
+// queries in stores are written with "?" placeholders, rebindDB and rebindTx
+// convert them to the bindvar type of driver before executing,
+// and run them with ctx so they are traced as children of the span in ctx
+type rebindDB struct {
+	*sqlx.DB
+	ctx context.Context
+}
+
+func (db rebindDB) Exec(query string, args ...interface{}) (res sql.Result, err error) {
+	ctx, end := startStatement(db.ctx, db.DriverName(), query)
+	defer func() { end(err) }()
+	return db.DB.ExecContext(ctx, db.Rebind(query), args...)
+}
+
+func (db rebindDB) Query(query string, args ...interface{}) (rows *sql.Rows, err error) {
+	ctx, end := startStatement(db.ctx, db.DriverName(), query)
+	defer func() { end(err) }()
+	return db.DB.QueryContext(ctx, db.Rebind(query), args...)
+}
+
+// the error of QueryRow is deferred to Scan, so it is not recorded
+func (db rebindDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	ctx, end := startStatement(db.ctx, db.DriverName(), query)
+	defer end(nil)
+	return db.DB.QueryRowContext(ctx, db.Rebind(query), args...)
+}
+
+func (db rebindDB) Get(dest interface{}, query string, args ...interface{}) (err error) {
+	ctx, end := startStatement(db.ctx, db.DriverName(), query)
+	defer func() { end(err) }()
+	return db.DB.GetContext(ctx, dest, db.Rebind(query), args...)
+}
+
+func (db rebindDB) Select(dest interface{}, query string, args ...interface{}) (err error) {
+	ctx, end := startStatement(db.ctx, db.DriverName(), query)
+	defer func() { end(err) }()
+	return db.DB.SelectContext(ctx, dest, db.Rebind(query), args...)
+}
+
+type rebindTx struct {
+	*sqlx.Tx
+	ctx context.Context
+}
+
+func (tx rebindTx) Exec(query string, args ...interface{}) (res sql.Result, err error) {
+	ctx, end := startStatement(tx.ctx, tx.DriverName(), query)
+	defer func() { end(err) }()
+	return tx.Tx.ExecContext(ctx, tx.Rebind(query), args...)
+}
+
+func (tx rebindTx) Get(dest interface{}, query string, args ...interface{}) (err error) {
+	ctx, end := startStatement(tx.ctx, tx.DriverName(), query)
+	defer func() { end(err) }()
+	return tx.Tx.GetContext(ctx, dest, tx.Rebind(query), args...)
+}
+
+// startStatement start the span of statement as a child of the span in ctx, call the returned
+// func with the error of statement to end the span and record the latency
+func startStatement(ctx context.Context, driver string, query string) (context.Context, func(err error)) {
+	start := time.Now()
+	operation := metrics.Operation(query)
+	ctx, span := tracing.Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String(dialectOf(driver).system),
+			semconv.DBOperation(operation),
+			semconv.DBStatement(query)))
+
+	return ctx, func(err error) {
+		// not found is an expected result rather than failure
+		if err != nil && err != sql.ErrNoRows {
+			tracing.Fail(span, err)
+		}
+		span.End()
+		metrics.DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+// getter is implemented by both rebindDB and rebindTx
+type getter interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+}
+
+// insert execute the insert statement and return the id of the new row
+func (c conn) insert(query string, args ...interface{}) (int64, error) {
+	if c.dialect.returning {
+		var id int64
+		err := c.db.Get(&id, strings.TrimSuffix(query, ";")+" returning c_id", args...)
+		return id, err
+	}
+
+	res, err := c.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// withTx run f inside a transaction, commit if f return nil, otherwise rollback
+// and return the error of f.
+func withTx(db rebindDB, f func(tx rebindTx) error) error {
+	sqlxTx, err := db.BeginTxx(db.ctx, nil)
+	if err != nil {
+		return err
+	}
+	tx := rebindTx{Tx: sqlxTx, ctx: db.ctx}
+
+	if err = f(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logrus.Error(rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// notFound convert sql.ErrNoRows to store.ErrNotFound
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+// affected return true if any row affected by the statement
+func affected(res sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// sortColumn map the sort option in request to the column name
+func sortColumn(sortBy string) string {
+	switch sortBy {
+	case "createTime":
+		return "c_create_time"
+	case "modifyTime":
+		return "c_modify_time"
+	case "name":
+		return "c_name"
+	default:
+		return "c_id"
+	}
+}
@@ -0,0 +1,112 @@
+package sqlstore
+
+import (
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+)
+
+type tokenStore struct {
+	conn
+}
+
+////////// Session Part ///////////
+
+func (s *tokenStore) CreateSession(userID int64, token string, duration int, userAgent string, ip string) error {
+	_, err := s.db.Exec("insert into t_login_token"+
+		" (c_user_id, c_token, c_expire_time, c_duration, c_user_agent, c_ip) values"+
+		" (?, ?, "+s.dialect.later("?", "day")+", ?, ?, ?)",
+		userID, token, duration, duration, userAgent, ip)
+	return err
+}
+
+func (s *tokenStore) GetSession(token string) (store.Session, error) {
+	var session store.Session
+	row := s.db.QueryRow("select c_id, c_user_id, c_duration, case when c_expire_time < "+
+		s.dialect.later("c_duration * 12", "hour")+" then 1 else 0 end from t_login_token"+
+		" where c_token = ? and c_expire_time > "+s.dialect.now, token)
+	err := row.Scan(&session.ID, &session.UserID, &session.Duration, &session.Renew)
+	return session, notFound(err)
+}
+
+func (s *tokenStore) TouchSession(sessionID int64) error {
+	_, err := s.db.Exec("update t_login_token set c_last_used_time = "+s.dialect.now+
+		", c_expire_time = case when c_expire_time < "+s.dialect.later("c_duration * 12", "hour")+
+		" then "+s.dialect.later("c_duration", "day")+" else c_expire_time end where c_id = ?", sessionID)
+	return err
+}
+
+func (s *tokenStore) ListSessions(userID int64) ([]dto.SessionDetail, error) {
+	var sqlCommand string = "select c_id, c_user_agent, c_ip, c_create_time, c_last_used_time, c_expire_time" +
+		" from t_login_token where c_user_id = ? and c_expire_time > " + s.dialect.now +
+		" order by c_last_used_time desc;"
+	var sessions []dto.SessionDetail = make([]dto.SessionDetail, 0)
+	err := s.db.Select(&sessions, sqlCommand, userID)
+	return sessions, err
+}
+
+func (s *tokenStore) RevokeSession(userID int64, sessionID int64) (bool, error) {
+	return affected(s.db.Exec("delete from t_login_token where c_id = ? and c_user_id = ?", sessionID, userID))
+}
+
+func (s *tokenStore) RevokeSessionByToken(token string) error {
+	_, err := s.db.Exec("delete from t_login_token where c_token = ?", token)
+	return err
+}
+
+func (s *tokenStore) RevokeAllSessions(userID int64) error {
+	_, err := s.db.Exec("delete from t_login_token where c_user_id = ?", userID)
+	return err
+}
+
+func (s *tokenStore) RemoveExpiredSessions() (int64, error) {
+	res, err := s.db.Exec("delete from t_login_token where c_expire_time < " + s.dialect.now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *tokenStore) CountActiveSessions() (int64, error) {
+	var count int64
+	err := s.db.Get(&count, "select count(*) from t_login_token where c_expire_time > "+s.dialect.now)
+	return count, err
+}
+
+////////// API Key Part ///////////
+
+func (s *tokenStore) CreateAPIKey(userID int64, name string, hash []byte, prefix string, scopes string,
+	expireDays int) (int64, error) {
+	// null expire time means never expire
+	var sqlCommand string = "insert into t_api_key (c_user_id, c_name, c_key_hash, c_prefix, c_scopes, c_expire_time)" +
+		" values (?, ?, ?, ?, ?, " + s.dialect.later("?", "day") + ")"
+	var expire interface{} = nil
+	if expireDays > 0 {
+		expire = expireDays
+	}
+	return s.insert(sqlCommand, userID, name, hash, prefix, scopes, expire)
+}
+
+func (s *tokenStore) GetAPIKey(hash []byte) (store.APIKey, error) {
+	var key store.APIKey
+	row := s.db.QueryRow("select c_id, c_user_id, c_scopes from t_api_key where c_key_hash = ?"+
+		" and (c_expire_time is null or c_expire_time > "+s.dialect.now+")", hash)
+	err := row.Scan(&key.ID, &key.UserID, &key.Scopes)
+	return key, notFound(err)
+}
+
+func (s *tokenStore) TouchAPIKey(keyID int64) error {
+	_, err := s.db.Exec("update t_api_key set c_last_used_time = "+s.dialect.now+" where c_id = ?", keyID)
+	return err
+}
+
+func (s *tokenStore) ListAPIKeys(userID int64) ([]dto.APIKeyDetail, error) {
+	const sqlCommand string = "select c_id, c_name, c_prefix, c_scopes, c_create_time, c_last_used_time, c_expire_time" +
+		" from t_api_key where c_user_id = ? order by c_id;"
+	var keys []dto.APIKeyDetail = make([]dto.APIKeyDetail, 0)
+	err := s.db.Select(&keys, sqlCommand, userID)
+	return keys, err
+}
+
+func (s *tokenStore) RevokeAPIKey(userID int64, keyID int64) (bool, error) {
+	return affected(s.db.Exec("delete from t_api_key where c_id = ? and c_user_id = ?", keyID, userID))
+}
@@ -0,0 +1,81 @@
+package sqlstore
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/store"
+)
+
+func TestTouchSession(t *testing.T) {
+	conn, st := openTestDB(t)
+	testTouchSession(t, conn, st)
+}
+
+func testTouchSession(t *testing.T, conn *sqlx.DB, st *store.Store) {
+	userID, err := st.Users.Create("session", []byte("hash"), "session@example.com", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = st.Tokens.CreateSession(userID, "token", 2, "curl/7.88", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// setExpire move the expire time of session to hours later from now
+	setExpire := func(hours int) {
+		t.Helper()
+		if _, err := conn.Exec(conn.Rebind("update t_login_token set c_expire_time = "+hoursLater(conn)+
+			", c_last_used_time = "+hoursLater(conn)), hours, -24); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// check the expire time is in range (from, to) hours later, and last used time is now
+	checkTimes := func(step string, from int, to int) {
+		t.Helper()
+		var inRange, used bool
+		err := conn.QueryRow(conn.Rebind("select c_expire_time > "+hoursLater(conn)+
+			" and c_expire_time < "+hoursLater(conn)+", c_last_used_time > "+hoursLater(conn)+
+			" from t_login_token"), from, to, -1).Scan(&inRange, &used)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !inRange {
+			t.Errorf("%s: expire time not in %d to %d hours later", step, from, to)
+		}
+		if !used {
+			t.Errorf("%s: last used time not updated", step)
+		}
+	}
+	touch := func(step string, wantRenew bool) {
+		t.Helper()
+		session, err := st.Tokens.GetSession("token")
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		if session.Renew != wantRenew {
+			t.Errorf("%s: renew %v, want %v", step, session.Renew, wantRenew)
+		}
+		if err = st.Tokens.TouchSession(session.ID); err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+	}
+
+	// more than half of duration left, not renewed
+	setExpire(30)
+	touch("more than half left", false)
+	checkTimes("more than half left", 29, 31)
+
+	// less than half left, renewed to the full duration
+	setExpire(20)
+	touch("less than half left", true)
+	checkTimes("less than half left", 47, 49)
+
+	// expired session is not found and swept
+	setExpire(-1)
+	if _, err = st.Tokens.GetSession("token"); err != store.ErrNotFound {
+		t.Errorf("GetSession() of expired session: err %v, want %v", err, store.ErrNotFound)
+	}
+	if n, err := st.Tokens.RemoveExpiredSessions(); err != nil || n != 1 {
+		t.Errorf("RemoveExpiredSessions() = %d, %v, want 1", n, err)
+	}
+}
@@ -0,0 +1,31 @@
+package sqlstore
+
+type userStore struct {
+	conn
+}
+
+func (s *userStore) CountByUsernameOrEmail(username string, email string) (int64, error) {
+	var cnt int64
+	err := s.db.Get(&cnt, "select count(c_id) from t_user where c_username = ? or c_email = ?", username, email)
+	return cnt, err
+}
+
+func (s *userStore) Create(username string, password []byte, email string, nickname string) (int64, error) {
+	return s.insert("insert into t_user (c_username, c_password, c_email, c_nickname) values (?, ?, ?, ?)",
+		username, password, email, nickname)
+}
+
+func (s *userStore) GetPassword(username string) (int64, []byte, error) {
+	var id int64
+	var password []byte
+	row := s.db.QueryRow("select c_id, c_password from t_user where c_username = ?", username)
+	if err := row.Scan(&id, &password); err != nil {
+		return 0, nil, notFound(err)
+	}
+	return id, password, nil
+}
+
+func (s *userStore) UpdatePassword(userID int64, password []byte) error {
+	_, err := s.db.Exec("update t_user set c_password = ? where c_id = ?", password, userID)
+	return err
+}
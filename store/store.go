@@ -0,0 +1,233 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	confcontent "github.com/leafee98/class-schedule-to-icalendar-restserver/content"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+)
+
+var (
+	// ErrNotFound is returned when the record doesn't exist or has been deleted
+	ErrNotFound = errors.New("not found")
+
+	// ErrDuplicate is returned when the record to create already exists
+	ErrDuplicate = errors.New("duplicate")
+
+	// ErrLimitExceeded is returned when the number of records reach the limit
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
+
+// Store contains all stores used by handlers
+type Store struct {
+	Users   UserStore
+	Configs ConfigStore
+	Plans   PlanStore
+	Shares  ShareStore
+	Favors  FavorStore
+	Tokens  TokenStore
+
+	// WithContext return the stores running statements with ctx, e: the context of http request,
+	// so the statements are canceled with the request and traced as its children
+	WithContext func(ctx context.Context) *Store
+}
+
+// UserStore manage user's account
+type UserStore interface {
+	// CountByUsernameOrEmail count the users using the username or email
+	CountByUsernameOrEmail(username string, email string) (int64, error)
+
+	Create(username string, password []byte, email string, nickname string) (int64, error)
+
+	// GetPassword return the user's ID and password hash by username
+	GetPassword(username string) (int64, []byte, error)
+
+	UpdatePassword(userID int64, password []byte) error
+}
+
+// ConfigStore manage configs, deleted configs are treated as not found
+type ConfigStore interface {
+	Create(req dto.ConfigCreateReq, ownerID int64) (int64, error)
+
+	// Get return the config and its owner's ID
+	Get(configID int64) (dto.ConfigGetRes, int64, error)
+
+	// GetByShare return the config of the config share
+	GetByShare(configShareID int64) (dto.ConfigGetRes, error)
+
+	// Owner return the config's owner ID and type
+	Owner(configID int64) (int64, int8, error)
+
+	Modify(req dto.ConfigModifyReq) error
+
+	// Remove set the deleted flag, return false if nothing removed
+	Remove(configID int64) (bool, error)
+
+	// List the configs owned by user,
+	// sortBy available value: "createTime", "modifyTime", "name", "id"
+	List(ownerID int64, sortBy string, offset int64, count int64) ([]dto.ConfigSummary, error)
+}
+
+// PlanStore manage plans, their relations with configs and plan tokens,
+// deleted plans are treated as not found
+type PlanStore interface {
+	Create(req dto.PlanCreateReq, ownerID int64) (int64, error)
+
+	// Get return the plan with its configs and config shares
+	Get(planID int64) (dto.PlanGetRes, error)
+
+	// Owner return the plan's owner ID
+	Owner(planID int64) (int64, error)
+
+	Modify(req dto.PlanModifyReq) error
+
+	// Remove set the deleted flag, return false if nothing removed
+	Remove(planID int64) (bool, error)
+
+	// List the plans owned by user,
+	// sortBy available value: "createTime", "modifyTime", "name", "id"
+	List(ownerID int64, sortBy string, offset int64, count int64) ([]dto.PlanSummary, error)
+
+	// CountGeneration add one to the generate count of plan, the modify time is kept
+	CountGeneration(planID int64) error
+
+	// ModifyTime return the time the plan or its relations modified
+	ModifyTime(planID int64) (time.Time, error)
+
+	// Configs return all configs used by the plan, including configs added by share
+	Configs(planID int64) ([]confcontent.Config, error)
+
+	// IDsByConfig return the plans using the config directly or by share
+	IDsByConfig(configID int64) ([]int64, error)
+
+	// IDsByConfigShare return the plans using the config share
+	IDsByConfigShare(configShareID int64) ([]int64, error)
+
+	ConfigExist(planID int64, configID int64) (bool, error)
+
+	// AddConfig return ErrDuplicate if the config is already added
+	AddConfig(planID int64, configID int64) error
+
+	// RemoveConfig return false if nothing removed
+	RemoveConfig(planID int64, configID int64) (bool, error)
+
+	ConfigShareExist(planID int64, configShareID int64) (bool, error)
+
+	// AddConfigShare return ErrDuplicate if the config share is already added
+	AddConfigShare(planID int64, configShareID int64) error
+
+	// RemoveConfigShare return false if nothing removed
+	RemoveConfigShare(planID int64, configShareID int64) (bool, error)
+
+	// CreateToken return ErrLimitExceeded if the plan already has max tokens
+	CreateToken(planID int64, token string, max int64) error
+
+	// TokenPlan return the plan ID of plan token
+	TokenPlan(token string) (int64, error)
+
+	// RevokeToken return false if nothing removed
+	RevokeToken(token string) (bool, error)
+
+	ListTokens(planID int64) ([]dto.PlanTokenDetail, error)
+}
+
+// ShareStore manage the shares of configs and plans, revoked shares are treated as not found
+type ShareStore interface {
+	CreateConfigShare(configID int64, remark string) (int64, error)
+	ModifyConfigShare(configShareID int64, remark string) error
+	RevokeConfigShare(configShareID int64) error
+	ListConfigShares(configID int64) ([]dto.ConfigShareDetail, error)
+	ConfigShareExist(configShareID int64) (bool, error)
+
+	// ConfigShareOwner return the owner ID of config shared
+	ConfigShareOwner(configShareID int64) (int64, error)
+
+	CreatePlanShare(planID int64, remark string) (int64, error)
+	ModifyPlanShare(planShareID int64, remark string) error
+	RevokePlanShare(planShareID int64) error
+	ListPlanShares(planID int64) ([]dto.PlanShareDetail, error)
+	PlanShareExist(planShareID int64) (bool, error)
+
+	// PlanShareOwner return the owner ID of plan shared
+	PlanShareOwner(planShareID int64) (int64, error)
+
+	// PlanSharePlan return the plan ID of plan share
+	PlanSharePlan(planShareID int64) (int64, error)
+}
+
+// FavorStore manage user's favourite config shares and plan shares
+type FavorStore interface {
+	// AddConfig return ErrDuplicate if the config share is already in favor
+	AddConfig(userID int64, configShareID int64) error
+	RemoveConfig(userID int64, configShareID int64) error
+	ListConfigs(userID int64, offset int64, count int64) ([]dto.FavorConfigSummary, error)
+
+	// AddPlan return ErrDuplicate if the plan share is already in favor
+	AddPlan(userID int64, planShareID int64) error
+	RemovePlan(userID int64, planShareID int64) error
+	ListPlans(userID int64, offset int64, count int64) ([]dto.FavorPlanSummary, error)
+}
+
+// Session is a login session found by token
+type Session struct {
+	ID     int64
+	UserID int64
+
+	// Duration in days
+	Duration int
+
+	// Renew is true if less than half of the duration left,
+	// the session will be renewed by TouchSession
+	Renew bool
+}
+
+// APIKey is an api key found by its hash
+type APIKey struct {
+	ID     int64
+	UserID int64
+	Scopes string
+}
+
+// TokenStore manage login sessions and api keys, expired ones are treated as not found
+type TokenStore interface {
+	// CreateSession store the login token valid for duration days
+	CreateSession(userID int64, token string, duration int, userAgent string, ip string) error
+
+	// GetSession return the session of login token
+	GetSession(token string) (Session, error)
+
+	// TouchSession update the last used time of session, and renew the session if
+	// less than half of its duration left
+	TouchSession(sessionID int64) error
+
+	ListSessions(userID int64) ([]dto.SessionDetail, error)
+
+	// RevokeSession return false if no such session of user
+	RevokeSession(userID int64, sessionID int64) (bool, error)
+
+	RevokeSessionByToken(token string) error
+
+	RevokeAllSessions(userID int64) error
+
+	// RemoveExpiredSessions return the number of sessions removed
+	RemoveExpiredSessions() (int64, error)
+
+	// CountActiveSessions count the unexpired sessions of all users
+	CountActiveSessions() (int64, error)
+
+	// CreateAPIKey store the hash of key, expireDays 0 means never expire
+	CreateAPIKey(userID int64, name string, hash []byte, prefix string, scopes string, expireDays int) (int64, error)
+
+	// GetAPIKey return the api key by its hash
+	GetAPIKey(hash []byte) (APIKey, error)
+
+	// TouchAPIKey update the last used time of api key
+	TouchAPIKey(keyID int64) error
+
+	ListAPIKeys(userID int64) ([]dto.APIKeyDetail, error)
+
+	// RevokeAPIKey return false if no such api key of user
+	RevokeAPIKey(userID int64, keyID int64) (bool, error)
+}
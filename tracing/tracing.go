@@ -0,0 +1,125 @@
+// Package tracing set up OpenTelemetry tracing of http requests, SQL statements and rpc calls,
+// and propagate the trace context to rpc server.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identify the spans of rest server in tracing backend
+const ServiceName = "csti-restserver"
+
+const instrumentationName = "github.com/leafee98/class-schedule-to-icalendar-restserver"
+
+var provider *sdktrace.TracerProvider
+
+// file written by the stdout exporter, closed on Shutdown
+var output io.Closer
+
+// Init set the global tracer provider exporting spans by trace-exporter,
+// and the propagator of W3C trace context and baggage.
+// opts are appended to the options of provider, e: a span recorder in tests.
+// Spans are dropped if trace-exporter is "none" and no span processor in opts.
+func Init(opts ...sdktrace.TracerProviderOption) error {
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return err
+	}
+
+	providerOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(float64(config.TraceSampleRate) / 100))),
+	}
+	exporter, err := newExporter(config.TraceExporter, config.TraceEndpoint)
+	if err != nil {
+		return err
+	}
+	if exporter != nil {
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider = sdktrace.NewTracerProvider(append(providerOpts, opts...)...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	return nil
+}
+
+// newExporter create the exporter by name, nil for "none".
+// endpoint is the address of OTLP collector, or the file written by "stdout" exporter
+func newExporter(name string, endpoint string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "none":
+		return nil, nil
+	case "otlp":
+		// the collector is verified by TLS unless OTEL_EXPORTER_OTLP_INSECURE=true,
+		// the CA and the endpoint if not set here are taken from the other OTEL_EXPORTER_OTLP_* environments
+		var opts []otlptracegrpc.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+		}
+		// the connection is established in background, the collector is not required to be up
+		return otlptracegrpc.New(context.Background(), opts...)
+	case "stdout":
+		var w io.Writer = os.Stdout
+		if endpoint != "" {
+			f, err := os.OpenFile(endpoint, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, err
+			}
+			w, output = f, f
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(w))
+	}
+	return nil, fmt.Errorf("unsupported trace exporter: %s", name)
+}
+
+// Shutdown export the buffered spans and release the exporter
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(ctx)
+	if output != nil {
+		if closeErr := output.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Start create a span as the child of the span in ctx, with the global tracer provider
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// Fail record err on span and mark it failed
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Extract return ctx with the trace context in carrier, e: the headers of request
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// Inject write the trace context of ctx into carrier, e: the metadata of rpc call
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
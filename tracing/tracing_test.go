@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+)
+
+// collector is a plaintext OTLP/gRPC collector counting the exported requests
+type collector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	received chan struct{}
+}
+
+func (c *collector) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	c.received <- struct{}{}
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func startCollector(t *testing.T) (string, *collector) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &collector{received: make(chan struct{}, 1)}
+	s := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(s, c)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String(), c
+}
+
+// export a span to endpoint by the otlp exporter
+func export(t *testing.T, endpoint string) error {
+	t.Helper()
+	exporter, err := newExporter("otlp", endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Shutdown(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return exporter.ExportSpans(ctx, tracetest.SpanStubs{{Name: "test"}}.Snapshots())
+}
+
+// TestOTLPTLSByDefault check the spans are not sent to a plaintext collector unless allowed
+func TestOTLPTLSByDefault(t *testing.T) {
+	endpoint, _ := startCollector(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_INSECURE", "")
+	if err := export(t, endpoint); err == nil {
+		t.Fatal("expect exporting to a plaintext collector failed without OTEL_EXPORTER_OTLP_INSECURE")
+	}
+}
+
+func TestOTLPInsecure(t *testing.T) {
+	endpoint, c := startCollector(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_INSECURE", "true")
+	if err := export(t, endpoint); err != nil {
+		t.Fatalf("export to plaintext collector: %v", err)
+	}
+	select {
+	case <-c.received:
+	default:
+		t.Fatal("expect the spans received by collector")
+	}
+}
@@ -0,0 +1,98 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	confcontent "github.com/leafee98/class-schedule-to-icalendar-restserver/content"
+)
+
+// types of value a field could be, empty for any
+var fieldTypes = map[string]bool{"": true, "string": true, "number": true, "bool": true, "array": true, "object": true}
+
+// field describe one option of config content.
+// Items check each element when the field is an array,
+// Fields check the children when the field (or element of array) is an object.
+// The options not listed are allowed, so new options of rpc server could be used before listed here.
+type field struct {
+	Type     string           `json:"type"`
+	Required bool             `json:"required"`
+	Items    *field           `json:"items"`
+	Fields   map[string]field `json:"fields"`
+}
+
+// Schema is the options of config content by config type, it is written by hand after the config
+// format of the rpc server in use, e:
+//
+//	{
+//	    "global": {"semesterStartDate": {"type": "string", "required": true}},
+//	    "lesson": {"name": {"type": "string", "required": true},
+//	               "schedule": {"type": "array", "items": {"type": "object", "fields": {...}}}}
+//	}
+type Schema struct {
+	Global map[string]field `json:"global"`
+	Lesson map[string]field `json:"lesson"`
+}
+
+// schema checking the options of config content, nil to check the structure only
+var schema *Schema
+
+// Init load the schema from file at path, only the structure of content is checked if path is empty
+func Init(path string) error {
+	if path == "" {
+		schema = nil
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	s, err := parseSchema(data)
+	if err != nil {
+		return fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	schema = s
+	return nil
+}
+
+func parseSchema(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	for name, fields := range map[string]map[string]field{"global": s.Global, "lesson": s.Lesson} {
+		for fieldName, f := range fields {
+			if err := checkField(name+"."+fieldName, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &s, nil
+}
+
+// checkField refuse the unknown types in schema, so a typo won't reject every config
+func checkField(path string, f field) error {
+	if !fieldTypes[f.Type] {
+		return fmt.Errorf("%s: unknown type %q", path, f.Type)
+	}
+	if f.Items != nil {
+		if err := checkField(path+"[]", *f.Items); err != nil {
+			return err
+		}
+	}
+	for name, child := range f.Fields {
+		if err := checkField(path+"."+name, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldsOf return the options of config type in s
+func (s *Schema) fieldsOf(configType int8) map[string]field {
+	if configType == confcontent.TypeGlobal {
+		return s.Global
+	}
+	return s.Lesson
+}
@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+
+	confcontent "github.com/leafee98/class-schedule-to-icalendar-restserver/content"
+	"github.com/leafee98/class-schedule-to-icalendar-restserver/dto"
+)
+
+// Validate check the config type, parse the content by its format and check its options
+// with the schema of its type loaded by Init. return nil if the content is valid, or every problem found.
+//
+// Without schema, only the structure used to build the envelope is checked,
+// that is the content must be an object (or table in toml).
+func Validate(content string, format int8, configType int8) []dto.ConfigFieldError {
+	if configType != confcontent.TypeGlobal && configType != confcontent.TypeLesson {
+		return []dto.ConfigFieldError{{Field: "type", Message: fmt.Sprintf("unsupported config type: %d", configType)}}
+	}
+
+	parsed, err := confcontent.Parse(content, format)
+	if err != nil {
+		return []dto.ConfigFieldError{{Field: "content", Message: err.Error()}}
+	}
+	if schema == nil {
+		return nil
+	}
+
+	var errs []dto.ConfigFieldError
+	checkObject(&errs, "", parsed, schema.fieldsOf(configType))
+	return errs
+}
+
+func checkObject(errs *[]dto.ConfigFieldError, path string, obj map[string]interface{}, fields map[string]field) {
+	// sorted so the errors are in the same order every time
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := fields[name]
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		v, exist := obj[name]
+		if !exist || v == nil {
+			if f.Required {
+				*errs = append(*errs, dto.ConfigFieldError{Field: fieldPath, Message: "required field is missing"})
+			}
+			continue
+		}
+		checkValue(errs, fieldPath, v, f)
+	}
+}
+
+func checkValue(errs *[]dto.ConfigFieldError, path string, v interface{}, f field) {
+	if f.Type != "" && typeOf(v) != f.Type {
+		*errs = append(*errs, dto.ConfigFieldError{Field: path, Message: "should be " + f.Type})
+		return
+	}
+
+	switch typeOf(v) {
+	case "object":
+		checkObject(errs, path, v.(map[string]interface{}), f.Fields)
+	case "array":
+		if f.Items == nil {
+			return
+		}
+		for i, item := range toSlice(v) {
+			checkValue(errs, fmt.Sprintf("%s[%d]", path, i), item, *f.Items)
+		}
+	}
+}
+
+// typeOf return the type of value parsed by content.Parse, in which the toml's datetime is already string
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64, int64:
+		return "number"
+	case bool:
+		return "bool"
+	case []interface{}, []map[string]interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// toSlice unify the array decoded from json and toml's array of tables
+func toSlice(v interface{}) []interface{} {
+	switch arr := v.(type) {
+	case []interface{}:
+		return arr
+	case []map[string]interface{}:
+		res := make([]interface{}, 0, len(arr))
+		for _, item := range arr {
+			res = append(res, item)
+		}
+		return res
+	}
+	return nil
+}
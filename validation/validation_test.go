@@ -0,0 +1,141 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	confcontent "github.com/leafee98/class-schedule-to-icalendar-restserver/content"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name       string
+		content    string
+		format     int8
+		configType int8
+		field      string
+	}{
+		// the options are not known here, any object is accepted
+		{"json object", `{"anyOption": [1, "two"]}`, confcontent.FormatJSON, confcontent.TypeGlobal, ""},
+		{"toml table", "name = \"math\"\n[[anything]]\nkey = 2021-03-01\n", confcontent.FormatTOML, confcontent.TypeLesson, ""},
+		{"empty object", `{}`, confcontent.FormatJSON, confcontent.TypeLesson, ""},
+		{"json array", `[{"name": "math"}]`, confcontent.FormatJSON, confcontent.TypeLesson, "content"},
+		{"json null", `null`, confcontent.FormatJSON, confcontent.TypeGlobal, "content"},
+		{"broken toml", `name = `, confcontent.FormatTOML, confcontent.TypeGlobal, "content"},
+		{"unknown format", `{}`, 3, confcontent.TypeGlobal, "content"},
+		{"unknown type", `{}`, confcontent.FormatJSON, 3, "type"},
+	}
+	for _, c := range cases {
+		errs := Validate(c.content, c.format, c.configType)
+		if c.field == "" {
+			if len(errs) != 0 {
+				t.Errorf("%s: unexpected errors %+v", c.name, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 || errs[0].Field != c.field {
+			t.Errorf("%s: errors %+v, want one on %s", c.name, errs, c.field)
+		}
+	}
+}
+
+// testSchema is in the shape of README example
+const testSchema = `{
+	"global": {
+		"semesterStartDate": {"type": "string", "required": true},
+		"timezone": {"type": "string"}
+	},
+	"lesson": {
+		"name": {"type": "string", "required": true},
+		"teacher": {"type": "string"},
+		"schedule": {"type": "array", "required": true, "items": {"type": "object", "fields": {
+			"week": {"type": "array", "required": true, "items": {"type": "number"}},
+			"room": {}
+		}}}
+	}
+}`
+
+// useSchema load schema for the test, the structure only checking is restored when the test end
+func useSchema(t *testing.T, data string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Init("") })
+}
+
+func TestValidateSchema(t *testing.T) {
+	useSchema(t, testSchema)
+
+	const validLesson = `{"name": "math", "schedule": [{"week": [1, 2], "room": 101}], "extra": true}`
+	cases := []struct {
+		name       string
+		content    string
+		format     int8
+		configType int8
+		fields     []string
+	}{
+		{"valid global", `{"semesterStartDate": "2021-03-01", "unknown": 1}`, confcontent.FormatJSON, confcontent.TypeGlobal, nil},
+		{"valid lesson", validLesson, confcontent.FormatJSON, confcontent.TypeLesson, nil},
+		{"valid toml lesson", "name = \"math\"\n[[schedule]]\nweek = [1, 2]\n", confcontent.FormatTOML, confcontent.TypeLesson, nil},
+		{"toml date is string", "semesterStartDate = 2021-03-01\n", confcontent.FormatTOML, confcontent.TypeGlobal, nil},
+
+		// each required field
+		{"missing semesterStartDate", `{"timezone": "UTC"}`, confcontent.FormatJSON, confcontent.TypeGlobal, []string{"semesterStartDate"}},
+		{"null semesterStartDate", `{"semesterStartDate": null}`, confcontent.FormatJSON, confcontent.TypeGlobal, []string{"semesterStartDate"}},
+		{"missing name", `{"schedule": []}`, confcontent.FormatJSON, confcontent.TypeLesson, []string{"name"}},
+		{"missing schedule", `{"name": "math"}`, confcontent.FormatJSON, confcontent.TypeLesson, []string{"schedule"}},
+		{"missing week", `{"name": "math", "schedule": [{"week": [1]}, {}]}`, confcontent.FormatJSON, confcontent.TypeLesson, []string{"schedule[1].week"}},
+		{"missing in toml table", "name = \"math\"\n[[schedule]]\nroom = 1\n", confcontent.FormatTOML, confcontent.TypeLesson, []string{"schedule[0].week"}},
+
+		// value types
+		{"misspelled and wrong type", `{"nmae": "math", "teacher": 1, "schedule": {}}`, confcontent.FormatJSON, confcontent.TypeLesson,
+			[]string{"name", "schedule", "teacher"}},
+		{"wrong type of element", `{"name": "math", "schedule": [{"week": [1, "two"]}, 3]}`, confcontent.FormatJSON, confcontent.TypeLesson,
+			[]string{"schedule[0].week[1]", "schedule[1]"}},
+		{"any type", `{"name": "math", "schedule": [{"week": [], "room": [1]}]}`, confcontent.FormatJSON, confcontent.TypeLesson, nil},
+
+		// the structure is checked before options
+		{"broken json", `{"name": `, confcontent.FormatJSON, confcontent.TypeLesson, []string{"content"}},
+		{"unknown type", validLesson, confcontent.FormatJSON, 3, []string{"type"}},
+	}
+	for _, c := range cases {
+		errs := Validate(c.content, c.format, c.configType)
+		var fields []string
+		for _, e := range errs {
+			if e.Message == "" {
+				t.Errorf("%s: no message on %s", c.name, e.Field)
+			}
+			fields = append(fields, e.Field)
+		}
+		if !reflect.DeepEqual(fields, c.fields) {
+			t.Errorf("%s: errors on %v, want %v", c.name, fields, c.fields)
+		}
+	}
+}
+
+func TestInitSchema(t *testing.T) {
+	for name, data := range map[string]string{
+		"broken json":  `{"global": `,
+		"unknown type": `{"lesson": {"name": {"type": "str"}}}`,
+		"nested type":  `{"lesson": {"schedule": {"type": "array", "items": {"fields": {"week": {"type": "int"}}}}}}`,
+	} {
+		path := filepath.Join(t.TempDir(), "schema.json")
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := Init(path); err == nil {
+			t.Errorf("%s: expect refused", name)
+		}
+	}
+	if err := Init(filepath.Join(t.TempDir(), "not-exist.json")); err == nil {
+		t.Error("expect missing file refused")
+	}
+	Init("")
+}